@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchRepo writes a repo with files items in every directory, and width
+// subrepos in every directory down to depth levels. Every tenth item is a
+// host file.
+func benchRepo(b *testing.B, files, width, depth int) string {
+	dir, err := ioutil.TempDir("", "sagacity-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var fill func(dir string, level int)
+	fill = func(dir string, level int) {
+		os.MkdirAll(dir, 0755)
+		ioutil.WriteFile(filepath.Join(dir, "_repo.yaml"), []byte("summary: Generated\n"), 0644)
+
+		for x := 0; x < files; x++ {
+			content := fmt.Sprintf("type: note\nsummary: Item %d\nowner: team%d\nbody: |\n  Some text about item %d.\n", x, x%5, x)
+			if x%10 == 0 {
+				content = fmt.Sprintf("type: host\nsummary: Hosts %d\ntypes:\n  web:\n    hosts:\n      - fqdn: web%d.company.net\n      - fqdn: web%d.company.net\n", x, x, x+1)
+			}
+			ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("item%d.yaml", x)), []byte(content), 0644)
+		}
+
+		if level < depth {
+			for x := 0; x < width; x++ {
+				fill(filepath.Join(dir, fmt.Sprintf("sub%d", x)), level+1)
+			}
+		}
+	}
+	fill(dir, 0)
+
+	return dir
+}
+
+func BenchmarkNewRepo(b *testing.B) {
+	sizes := []struct {
+		name                string
+		files, width, depth int
+	}{
+		{"flat", 2000, 0, 0},
+		{"wide", 20, 10, 2},
+		{"deep", 5, 2, 8},
+	}
+
+	for _, size := range sizes {
+		b.Run(size.name, func(b *testing.B) {
+			dir := benchRepo(b, size.files, size.width, size.depth)
+			defer os.RemoveAll(dir)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for x := 0; x < b.N; x++ {
+				NewRepo(dir)
+			}
+		})
+	}
+}
+
+func BenchmarkLoadItem(b *testing.B) {
+	b.ReportAllocs()
+	for x := 0; x < b.N; x++ {
+		LoadItem(&Repo{}, "test/meta/runbook.yaml")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// findItem returns the item at a path of keys, like `infra dns zones`, starting
+// with the key of a root repo
+func findItem(repos map[string]*Repo, path []string) (Item, error) {
+	if len(path) < 2 {
+		return nil, fmt.Errorf("Give the key of a repo and then the path to an item in it")
+	}
+
+	r, err := findRepo(repos, path[0])
+	if err != nil {
+		return nil, err
+	}
+
+	item, remaining, err := r.GetItem(path[1:])
+	if err != nil {
+		return nil, err
+	}
+	if len(remaining) != 0 {
+		return nil, fmt.Errorf("Nothing below %s in %s", item.ID(), item.Path())
+	}
+
+	return item, nil
+}
+
+// CatItem prints the file of an item exactly as it is on disk
+func CatItem(repos map[string]*Repo, path []string) {
+	item, err := findItem(repos, path)
+	if err != nil {
+		fmt.Println(err)
+		exit(exitNotFound)
+	}
+
+	data, err := repoFS.ReadFile(item.Path())
+	if err != nil {
+		fmt.Println(err)
+		exit(exitError)
+	}
+
+	os.Stdout.Write(data)
+}
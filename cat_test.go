@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func testCatRepos() map[string]*Repo {
+	return map[string]*Repo{"prefix": NewRepo("test/prefix/")}
+}
+
+func TestFindItemInSubrepo(t *testing.T) {
+	assert := assert.New(t)
+
+	item, err := findItem(testCatRepos(), []string{"prefix", "firewalls", "rules"})
+
+	assert.Nil(err)
+	assert.Equal("rules", item.ID())
+}
+
+func TestFindItemErrors(t *testing.T) {
+	assert := assert.New(t)
+	repos := testCatRepos()
+
+	_, err := findItem(repos, []string{"prefix"})
+	assert.NotNil(err)
+
+	_, err = findItem(repos, []string{"nope", "rules"})
+	assert.NotNil(err)
+
+	_, err = findItem(repos, []string{"prefix", "firewalls"})
+	assert.EqualError(err, "firewalls is a subrepo, not an item")
+}
+
+func ExampleCatItem() {
+	CatItem(testCatRepos(), []string{"prefix", "firewalls", "rules"})
+	// Output: type: info
+	// body: Rules
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"github.com/codegangsta/cli"
 	"os"
 	"sort"
+	"strings"
+	"time"
 )
 
 // BuildCLI builds the base CLI App() object
@@ -12,7 +15,42 @@ func BuildCLI(repos map[string]*Repo, conf *Config) (app *cli.App) {
 	app.Name = "sp"
 	app.EnableBashCompletion = true
 	app.Usage = "spread and use knowledge!"
+	app.Version = version
 	app.HideHelp = true
+	app.Flags = []cli.Flag{
+		cli.BoolFlag{
+			Name:  "quiet",
+			Usage: "do not report progress or skipped directories while loading",
+		},
+		cli.BoolFlag{
+			Name:  "no-pager",
+			Usage: "never send long output through $PAGER",
+		},
+		cli.BoolFlag{
+			Name:  "show-secrets",
+			Usage: "print the values of the secrets of items, not only their names",
+		},
+		cli.BoolFlag{
+			Name:  "global",
+			Usage: "use the configured repos even inside of a project with a .sagacity file",
+		},
+		cli.StringFlag{
+			Name:  "git-timeout",
+			Usage: "kill git commands that take longer than this (default 10m)",
+		},
+		cli.StringFlag{
+			Name:  "root",
+			Usage: "only load the repo in this directory, whatever the configuration says",
+		},
+		cli.BoolFlag{
+			Name:  "full",
+			Usage: "never cut summaries to fit on the screen",
+		},
+		cli.BoolFlag{
+			Name:  "plain",
+			Usage: "print bare text without colors, indexes or wrapping",
+		},
+	}
 
 	repolen := len(repos)
 	commands := make([]cli.Command, 0, repolen+2)
@@ -31,6 +69,286 @@ func BuildCLI(repos map[string]*Repo, conf *Config) (app *cli.App) {
 	// Repo management commands are only present if we are not doing bash completion.
 	if !isCompleting() {
 		commands = append(commands, []cli.Command{
+			{
+				Name:     "count",
+				Usage:    "count [items|control|subrepos|hosts]",
+				HideHelp: true,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "repo, r",
+						Usage: "only count the repo with this key",
+					},
+				},
+				Action: func(c *cli.Context) {
+					PrintCount(conf, c.String("repo"), c.Args().First())
+				},
+			},
+			{
+				Name:     "cat",
+				Usage:    "cat <repo> [subrepo...] <item>",
+				HideHelp: true,
+				Action: func(c *cli.Context) {
+					CatItem(repos, c.Args())
+				},
+			},
+			{
+				Name:     "show",
+				Usage:    "show <repo> [subrepo...] <item> [--format text|yaml]",
+				HideHelp: true,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "format",
+						Value: "text",
+						Usage: "text to print the item, or yaml for its fields as normalized YAML",
+					},
+				},
+				Action: func(c *cli.Context) {
+					ShowItem(repos, c.Args(), c.String("format"))
+				},
+			},
+			{
+				Name:     "diff",
+				Usage:    "diff <repo/path/to/item> <repo/path/to/other>",
+				HideHelp: true,
+				Action: func(c *cli.Context) {
+					if len(c.Args()) != 2 {
+						fmt.Println("Give the paths of two items, like infra/runbooks/deploy")
+						exit(exitError)
+					}
+					DiffItems(repos, c.Args()[0], c.Args()[1])
+				},
+			},
+			{
+				Name:     "rename",
+				Usage:    "rename <repo/path/to/item> <new-key>",
+				HideHelp: true,
+				Action: func(c *cli.Context) {
+					if len(c.Args()) != 2 {
+						fmt.Println("Give the path of an item and its new key, like infra/runbooks/deploy release")
+						exit(exitError)
+					}
+					RenameItem(repos, c.Args()[0], c.Args()[1])
+				},
+			},
+			{
+				Name:     "move",
+				Usage:    "move <repo/path/to/item> <repo/path/to/subrepo>",
+				HideHelp: true,
+				Action: func(c *cli.Context) {
+					if len(c.Args()) != 2 {
+						fmt.Println("Give the path of an item and the subrepo to move it to, like infra/runbooks/deploy infra/archive")
+						exit(exitError)
+					}
+					MoveItem(repos, c.Args()[0], c.Args()[1])
+				},
+			},
+			{
+				Name:     "connect",
+				Usage:    "connect <fqdn> | connect --fqdn <fqdn>",
+				HideHelp: true,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "fqdn",
+						Usage: "connect to this FQDN directly, without looking it up in the repos",
+					},
+				},
+				Action: func(c *cli.Context) {
+					if fqdn := c.String("fqdn"); fqdn != "" {
+						ConnectDirect(conf, fqdn)
+						return
+					}
+					if len(c.Args()) == 0 {
+						fmt.Println("Specify the FQDN of a host")
+						exit(exitError)
+					}
+					Connect(repos, c.Args()[0])
+				},
+			},
+			{
+				Name:     "exec",
+				Usage:    "exec <selector> -- <command...> | exec --stdin -- <command...>",
+				HideHelp: true,
+				Flags: append([]cli.Flag{
+					cli.BoolFlag{
+						Name:  "stdin",
+						Usage: "run on the FQDNs given on stdin, one per line",
+					},
+				}, fanOutFlags...),
+				Action: func(c *cli.Context) {
+					if !c.Bool("stdin") {
+						ExecSelector(repos, c.Args())
+						return
+					}
+					if len(c.Args()) == 0 {
+						fmt.Println("Specify the command to run")
+						exit(exitError)
+					}
+					ExecStdin(repos, conf, remoteCommand(c.Args())[0], fanOutOptions(c))
+				},
+			},
+			{
+				Name:     "copy-id",
+				Usage:    "copy-id <fqdn> [--identity <file>]",
+				HideHelp: true,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "identity, i",
+						Usage: "the public key to install, instead of the ssh-copy-id default",
+					},
+				},
+				Action: func(c *cli.Context) {
+					if len(c.Args()) == 0 {
+						fmt.Println("Specify the FQDN of a host")
+						exit(exitError)
+					}
+					CopyID(repos, c.Args()[0], c.String("identity"))
+				},
+			},
+			{
+				Name:     "sync-manifest",
+				Usage:    "sync-manifest [file]",
+				HideHelp: true,
+				Action: func(c *cli.Context) {
+					fn := conf.Manifest
+					if len(c.Args()) != 0 {
+						fn = c.Args()[0]
+					}
+					if fn == "" {
+						fmt.Println("No manifest configured or given")
+						exit(exitError)
+					}
+
+					if code := SyncManifest(conf, fn); code != 0 {
+						exit(code)
+					}
+				},
+			},
+			{
+				Name:     "open-repo",
+				Usage:    "open-repo <key> [--shell]",
+				HideHelp: true,
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "shell",
+						Usage: "start a shell in the repo directory instead of printing it",
+					},
+				},
+				Action: func(c *cli.Context) {
+					if len(c.Args()) == 0 {
+						fmt.Println("Specify the key of a repo")
+						exit(exitError)
+					}
+					OpenRepo(repos, c.Args()[0], c.Bool("shell"))
+				},
+			},
+			{
+				Name:     "import-hosts",
+				Usage:    "import-hosts <inventory> [output.yaml]",
+				HideHelp: true,
+				Action: func(c *cli.Context) {
+					args := c.Args()
+					if len(args) == 0 {
+						fmt.Println("Give the inventory to import")
+						exit(exitError)
+					}
+					ImportHosts(args[0], args.Get(1))
+				},
+			},
+			{
+				Name:     "kind",
+				Usage:    "kind <kind>",
+				HideHelp: true,
+				Action: func(c *cli.Context) {
+					if len(c.Args()) != 1 {
+						fmt.Println("Give a kind of host, like postgres. Known kinds are:", strings.Join(Kinds(repos), ", "))
+						exit(exitError)
+					}
+					ListKind(repos, c.Args()[0])
+				},
+			},
+			{
+				Name:     "search",
+				Usage:    "search <term> [--exact] [--reverse]",
+				HideHelp: true,
+				Flags: append(append([]cli.Flag{}, filterFlags...),
+					cli.BoolFlag{
+						Name:  "exact",
+						Usage: "only match items whose key is the term",
+					},
+					reverseFlag,
+					countOnlyFlag,
+				),
+				Action: func(c *cli.Context) {
+					if len(c.Args()) != 1 {
+						fmt.Println("Give a term to search for, in quotes if it has spaces")
+						exit(exitError)
+					}
+					f, err := newFilter(c)
+					if err != nil {
+						fmt.Println(err)
+						exit(exitError)
+					}
+					SearchRepos(repos, c.Args()[0], c.Bool("exact"), f, c.Bool("count-only"), c.Bool("reverse"))
+				},
+			},
+			{
+				Name:     "lint",
+				Usage:    "lint [repo]",
+				HideHelp: true,
+				Action: func(c *cli.Context) {
+					Lint(repos, c.Args().First())
+				},
+			},
+			{
+				Name:     "prune",
+				Usage:    "prune [repo] [--recursive]",
+				HideHelp: true,
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name:  "recursive, r",
+						Usage: "check the subrepos as well",
+					},
+				},
+				Action: func(c *cli.Context) {
+					Prune(repos, c.Args().First(), c.Bool("recursive"))
+				},
+			},
+			{
+				Name:     "version",
+				Usage:    "show the version and build of sagacity",
+				HideHelp: true,
+				Action: func(c *cli.Context) {
+					fmt.Println(versionInfo())
+				},
+			},
+			{
+				Name:     "validate-hosts",
+				Usage:    "check that all hosts resolve in DNS",
+				HideHelp: true,
+				Flags: []cli.Flag{
+					cli.DurationFlag{
+						Name:  "timeout",
+						Value: 2 * time.Second,
+						Usage: "how long to wait for each lookup",
+					},
+					cli.BoolFlag{
+						Name:  "watch",
+						Usage: "check again and again until interrupted",
+					},
+					cli.DurationFlag{
+						Name:  "interval",
+						Value: 10 * time.Second,
+						Usage: "how long to wait between checks with --watch",
+					},
+				},
+				Action: func(c *cli.Context) {
+					if c.Bool("watch") {
+						WatchHosts(NewRepoSet(conf, repos), c.Duration("timeout"), c.Duration("interval"))
+						return
+					}
+					ValidateHosts(repos, c.Duration("timeout"))
+				},
+			},
 			{
 				Name:     "repo",
 				Usage:    "repo commands",
@@ -45,12 +363,37 @@ func BuildCLI(repos map[string]*Repo, conf *Config) (app *cli.App) {
 							AddRepo(conf, args[0])
 						},
 					},
+					{
+						Name:     "list",
+						Usage:    "list [--keys-only]",
+						HideHelp: true,
+						Flags: []cli.Flag{
+							cli.BoolFlag{
+								Name:  "keys-only",
+								Usage: "only print the keys, for scripting",
+							},
+						},
+						Action: func(c *cli.Context) {
+							ListRepos(repos, c.Bool("keys-only"))
+						},
+					},
 					{
 						Name:     "update",
-						Usage:    "update",
+						Usage:    "update [--all] [--retries 2]",
 						HideHelp: true,
+						Flags: []cli.Flag{
+							cli.BoolFlag{
+								Name:  "all",
+								Usage: "also pull subrepos that are git repositories of their own",
+							},
+							cli.IntFlag{
+								Name:  "retries",
+								Value: 2,
+								Usage: "how many times to retry pulls that fail because of the network",
+							},
+						},
 						Action: func(c *cli.Context) {
-							UpdateRepos(repos)
+							UpdateRepos(repos, c.Bool("all"), c.Int("retries"), conf.remote())
 						},
 					},
 				},
@@ -68,11 +411,84 @@ func BuildCLI(repos map[string]*Repo, conf *Config) (app *cli.App) {
 // not clutter the base command level, we avoid adding the repo management
 // commands whenever we are doing completion. Clean!
 func isCompleting() bool {
-	for _, arg := range os.Args {
-		if arg == "--generate-bash-completion" {
-			return true
+	// The shell always puts it last, after whatever is being completed
+	return len(os.Args) > 1 && os.Args[len(os.Args)-1] == "--generate-bash-completion"
+}
+
+// valueFlags are the global flags that take a value as the next argument
+var valueFlags = map[string]bool{"--git-timeout": true, "--root": true}
+
+// globalArgs returns the global flags at the start of the arguments, which
+// is everything before the first command or `--`
+//
+// Anything after that belongs to the command, like the remote command of
+// exec, and must never change how sagacity itself behaves.
+func globalArgs(args []string) []string {
+	for x := 1; x < len(args); x++ {
+		arg := args[x]
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			return args[1:x]
+		}
+		if valueFlags[arg] {
+			x++
 		}
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return args[1:]
+}
 
+// hasFlag returns boolean if the flag is among the global flags
+//
+// Some flags change how the repositories are loaded, which happens before the
+// CLI exists and can parse them for us.
+func hasFlag(flag string) bool {
+	for _, arg := range globalArgs(os.Args) {
+		if arg == flag {
+			return true
+		}
 	}
 	return false
 }
+
+// flagValue returns the value of a global flag given as `--flag value` or
+// `--flag=value`, for the same reason as hasFlag
+func flagValue(flag string) string {
+	args := globalArgs(os.Args)
+	for x, arg := range args {
+		if arg == flag && x+1 < len(args) {
+			return args[x+1]
+		}
+		if strings.HasPrefix(arg, flag+"=") {
+			return arg[len(flag)+1:]
+		}
+	}
+	return ""
+}
+
+// takeVerbosity removes -v, -vv and -vvv from the arguments and returns how
+// many v's there were in total
+//
+// They are passed on to ssh, and the CLI has no way of counting repeated
+// flags. This means that -v no longer shows the version; --version still does.
+//
+// Only the flags before the first command are taken, so that the ones in a
+// remote command like `grep -v err` are left alone.
+func takeVerbosity(args []string) (int, []string) {
+	level := 0
+	rest := make([]string, 0, len(args))
+	for x, arg := range args {
+		if x != 0 && !strings.HasPrefix(arg, "-") {
+			return level, append(rest, args[x:]...)
+		}
+
+		switch arg {
+		case "-v", "-vv", "-vvv":
+			level += len(arg) - 1
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return level, rest
+}
@@ -5,7 +5,10 @@ import (
 	"github.com/codegangsta/cli"
 	"github.com/fatih/color"
 	"os"
+	"os/exec"
 	"sort"
+	"strings"
+	"time"
 )
 
 func commandHostKey(hosts map[string]string) []string {
@@ -26,6 +29,7 @@ type Command struct {
 	Hosts      map[string]string `yaml:"hosts"`
 	id         string
 	path       string
+	mtime      time.Time
 	repo       *Repo
 }
 
@@ -37,6 +41,12 @@ func (c Command) MakeCLI() []cli.Command {
 			Name:     key,
 			HideHelp: true,
 			Action:   c.Execute,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "tty",
+					Usage: "allocate a pty for the command",
+				},
+			},
 		}
 		sc = append(sc, cc)
 	}
@@ -46,13 +56,19 @@ func (c Command) MakeCLI() []cli.Command {
 // Execute will execute the command specified by the item.
 //
 // If the `host` attribute is set, the command will be executed on the host(s)
-// specified.
+// specified. Otherwise it is executed locally.
 func (c *Command) Execute(cl *cli.Context) {
 	blue := color.New(color.FgBlue, color.Bold).SprintfFunc()
 	magenta := color.New(color.FgMagenta, color.Bold).SprintfFunc()
 	yellow := color.New(color.FgYellow, color.Bold).SprintfFunc()
 	green := color.New(color.FgGreen, color.Bold).SprintfFunc()
 
+	// Commands without any hosts are run right here.
+	if len(c.Hosts) == 0 {
+		c.executeLocal()
+		return
+	}
+
 	args := cl.Args()
 	if len(args) == 0 {
 		fmt.Println("Specify host targets:")
@@ -82,15 +98,52 @@ func (c *Command) Execute(cl *cli.Context) {
 	if !ask("Do you want to continue? [y/N] ") {
 		fmt.Println("Doing nothing.")
 
-		os.Exit(1)
+		exit(exitError)
 	}
 
 	repo := c.repo.ParentRepo()
 	host := repo.GetHost(hostdef)
-	host.Execute(c.RawCommand)
+	if host == nil {
+		// GetHost has already made sure that it is a host file and a category
+		fmt.Println("There are no hosts in", strings.Split(hostdef, " ")[1])
+		exit(exitNotFound)
+	}
+	host.TTY = host.TTY || cl.Bool("tty")
+	exitOnFailure(host.Execute(c.RawCommand))
 	return
 }
 
+// executeLocal runs the command on the local machine
+func (c *Command) executeLocal() {
+	blue := color.New(color.FgBlue, color.Bold).SprintfFunc()
+	magenta := color.New(color.FgMagenta, color.Bold).SprintfFunc()
+	yellow := color.New(color.FgYellow, color.Bold).SprintfFunc()
+
+	fmt.Println(
+		fmt.Sprintf("%s: %s\nRuns %s locally\n",
+			blue(c.ID()),
+			magenta(c.Summary()),
+			yellow(c.RawCommand),
+		),
+	)
+
+	if !ask("Do you want to continue? [y/N] ") {
+		fmt.Println("Doing nothing.")
+
+		exit(exitError)
+	}
+
+	cmd := exec.Command("sh", "-c", c.RawCommand)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		fmt.Println("Command failed:", err)
+		exit(exitError)
+	}
+}
+
 // ID returns the ID of the item
 func (c Command) ID() string {
 	return c.id
@@ -110,6 +163,11 @@ func (c Command) Path() string {
 	return c.path
 }
 
+// ModTime returns the modification time of the item's file
+func (c Command) ModTime() time.Time {
+	return c.mtime
+}
+
 // Summary returns the summary of the item
 func (c Command) Summary() string {
 	// TODO(thiderman): This doesn't feel right...
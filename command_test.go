@@ -1 +1,39 @@
 package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// answer makes ask read the given answer, returning a function that restores
+// stdin
+func answer(s string) func() {
+	orig := os.Stdin
+	f, _ := ioutil.TempFile("", "sagacity")
+	f.WriteString(s)
+	f.Seek(0, 0)
+	os.Stdin = f
+	return func() {
+		os.Stdin = orig
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+func TestCommandOnEmptyCategory(t *testing.T) {
+	assert := assert.New(t)
+	defer useFS(memFS{
+		"/mem/cmd/_repo.yaml":     "",
+		"/mem/cmd/deploy.yaml":    "type: command\ncommand: uptime\nhosts:\n  prod: web empty\n",
+		"/mem/cmd/hosts/web.yaml": "type: host\ntypes:\n  empty:\n    summary: Nothing here yet\n",
+	})()
+	defer answer("y\n")()
+	defer useRunner(&fakeRunner{})()
+
+	r := NewRepo("/mem/cmd")
+
+	assert.Nil(r.GetHost("web empty"))
+	assert.Equal(exitNotFound, exitCode(func() { runCLI(r, "deploy", "prod") }))
+}
@@ -4,16 +4,36 @@ import (
 	"io/ioutil"
 
 	"gopkg.in/yaml.v2"
+	"log"
 	"os"
 	"os/user"
 	"path/filepath"
+	"sync"
 )
 
 // Config contains the root configuration of a project
 type Config struct {
-	RepoRoot     string   `yaml:"repository_root"`
-	Repositories []string `yaml:"repositories"`
+	RepoRoot     string     `yaml:"repository_root"`
+	Repositories []string   `yaml:"repositories"`
+	Manifest     string     `yaml:"manifest,omitempty"`
+	SSHDefaults  SSHOptions `yaml:"ssh_defaults,omitempty"`
+	Hooks        bool       `yaml:"hooks,omitempty"`
+	Remote       string     `yaml:"remote,omitempty"`
+	Transcripts  string     `yaml:"transcripts,omitempty"`
+	GitTimeout   string     `yaml:"git_timeout,omitempty"`
+	MaxWidth     int        `yaml:"max_width,omitempty"`
+	SecretTool   string     `yaml:"secret_tool,omitempty"`
+	Quiet        bool       `yaml:"-"`
+	Project      string     `yaml:"-"`
 	filename     string
+	manifest     Manifest
+	manifestOnce sync.Once
+}
+
+// settings are what root repos inherit from the configuration, like subrepos
+// inherit the settings of their parent
+func (c *Config) settings() Settings {
+	return Settings{SSHDefaults: c.SSHDefaults}
 }
 
 // LoadConfig checks for configuration files and loads them
@@ -41,6 +61,15 @@ func LoadConfig(fn string) *Config {
 	return &c
 }
 
+// remote returns the name of the git remote to use for repos that do not set
+// one of their own
+func (c *Config) remote() string {
+	if c.Remote == "" {
+		return "origin"
+	}
+	return c.Remote
+}
+
 // persist saves the file to disk
 func (c *Config) persist() error {
 	// Create the directory if it doesn't exist
@@ -64,3 +93,51 @@ func (c *Config) AddRepo(dir string) error {
 	c.Repositories = append(c.Repositories, dir)
 	return c.persist()
 }
+
+// RepoDirs returns the directories of all the repositories to load
+//
+// These are the configured repositories, followed by any repositories in the
+// manifest that have been cloned but are not configured. Inside of a project,
+// it is only the repo of the project.
+func (c *Config) RepoDirs() []string {
+	if c.Project != "" {
+		return []string{c.Project}
+	}
+
+	dirs := append([]string{}, c.Repositories...)
+	if c.Manifest == "" {
+		return dirs
+	}
+
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		seen[dir] = true
+	}
+
+	for _, e := range c.loadManifest() {
+		dir := e.Dir(c.RepoRoot)
+		if seen[dir] || !isDir(dir) {
+			continue
+		}
+
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+
+	return dirs
+}
+
+// loadManifest loads the manifest the first time it is needed, so that
+// reloading the repos does not read it or complain about it again
+func (c *Config) loadManifest() Manifest {
+	c.manifestOnce.Do(func() {
+		m, err := LoadManifest(c.Manifest)
+		if err != nil {
+			log.Print("Could not load manifest: ", err)
+			return
+		}
+		c.manifest = m
+	})
+
+	return c.manifest
+}
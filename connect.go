@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/fatih/color"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HostMatch is a host along with where it was found
+type HostMatch struct {
+	Info     *HostInfo
+	Category string
+	Index    int
+	Host     Host
+}
+
+func (m HostMatch) String() string {
+	return fmt.Sprintf("%s: %s[%d]", m.Info.Path(), m.Category, m.Index)
+}
+
+// FindByFQDN returns every place in the host file where the FQDN is defined
+func (h *HostInfo) FindByFQDN(fqdn string) (matches []HostMatch) {
+	for _, key := range h.Types.List() {
+		for x, host := range h.Types[key].Hosts {
+			if host.FQDN == fqdn {
+				matches = append(matches, HostMatch{h, key, x, host})
+			}
+		}
+	}
+
+	return
+}
+
+// FindHost returns every place in all the repos where the FQDN is defined
+func FindHost(repos map[string]*Repo, fqdn string) (matches []HostMatch) {
+	keys := make([]string, 0, len(repos))
+	for key := range repos {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, h := range repos[key].HostInfos() {
+			matches = append(matches, h.FindByFQDN(fqdn)...)
+		}
+	}
+
+	return
+}
+
+// Connect opens a ssh connection to a host defined anywhere in the repos
+func Connect(repos map[string]*Repo, fqdn string) {
+	m := pickMatch(repos, fqdn)
+	exitOnFailure(m.Host.Execute())
+}
+
+// pickMatch finds the host with the FQDN in the repos, exiting if there is none
+//
+// If the host is defined in more than one place, the user gets to pick which
+// definition to use, since they can have different ssh options.
+func pickMatch(repos map[string]*Repo, fqdn string) HostMatch {
+	blue := color.New(color.FgBlue, color.Bold).SprintfFunc()
+	yellow := color.New(color.FgYellow).SprintfFunc()
+	hiyellow := color.New(color.FgHiYellow, color.Bold).SprintfFunc()
+
+	matches := FindHost(repos, fqdn)
+
+	var m HostMatch
+	switch len(matches) {
+	case 0:
+		fmt.Println("No host named", fqdn, "in any repo")
+		exit(exitNotFound)
+
+	case 1:
+		m = matches[0]
+
+	default:
+		fmt.Printf("%s is defined in several places:\n", blue(fqdn))
+		for x, match := range matches {
+			fmt.Printf("  %s%s%s %s\n", yellow("["), hiyellow("%d", x), yellow("]"), match)
+		}
+
+		x, ok := choose(fmt.Sprintf("Which one? [0-%d] ", len(matches)-1), len(matches))
+		if !ok {
+			fmt.Println("Doing nothing.")
+			exit(exitError)
+		}
+		m = matches[x]
+	}
+
+	return m
+}
+
+// CopyID installs a public key on a host with ssh-copy-id, reaching the host
+// the same way that connecting to it does
+func CopyID(repos map[string]*Repo, fqdn, identity string) {
+	blue := color.New(color.FgBlue, color.Bold).SprintfFunc()
+	red := color.New(color.FgRed, color.Bold).SprintfFunc()
+	green := color.New(color.FgGreen, color.Bold).SprintfFunc()
+
+	m := pickMatch(repos, fqdn)
+	fmt.Printf("Copying the key to %s (%s)\n", blue(fqdn), m)
+
+	err := sshRunner.Run(context.Background(), m.Host.copyIDCommand(identity), nil, os.Stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		fmt.Println(red("Copying the key failed: %s", err))
+		exit(exitError)
+	}
+	fmt.Println(green("The key is installed on %s", fqdn))
+}
+
+// copyIDCommand returns the ssh-copy-id command line that installs a key on
+// the host, with the same user, port and jumps as ssh gets. Without an
+// identity it installs the public half of the host's identity file.
+func (h *Host) copyIDCommand(identity string) []string {
+	args := []string{"ssh-copy-id"}
+	if identity == "" && h.IdentityFile != "" {
+		identity = h.IdentityFile + ".pub"
+	}
+	if identity != "" {
+		args = append(args, "-i", identity)
+	}
+	if h.IdentityFile != "" {
+		args = append(args, "-o", "IdentityFile="+h.IdentityFile)
+	}
+	if h.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(h.Port))
+	}
+	if len(h.Jumps) != 0 {
+		args = append(args, "-o", "ProxyJump="+strings.Join(h.Jumps, ","))
+	} else if h.Jump != "" {
+		args = append(args, "-o", "ProxyJump="+h.Jump)
+	}
+	for _, opt := range h.Options {
+		args = append(args, "-o", opt)
+	}
+	for _, opt := range h.keepAliveOptions() {
+		args = append(args, "-o", opt)
+	}
+
+	dest := h.FQDN
+	if h.User != "" {
+		dest = h.User + "@" + dest
+	}
+	return append(args, dest)
+}
+
+// ConnectDirect opens a ssh connection to any FQDN, whether or not a repo
+// defines it
+//
+// The host only gets the ssh defaults from the configuration, since there is
+// no host file to take anything else from.
+func ConnectDirect(conf *Config, fqdn string) {
+	exitOnFailure(directHost(conf, fqdn).Execute())
+}
+
+// directHost makes a host that is not defined in any repo
+func directHost(conf *Config, fqdn string) *Host {
+	return &Host{FQDN: fqdn, SSHOptions: conf.SSHDefaults}
+}
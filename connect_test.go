@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFindByFQDN(t *testing.T) {
+	assert := assert.New(t)
+	h := testHostInfo()
+
+	matches := h.FindByFQDN("db4.cluster3.company.net")
+
+	assert.Equal(1, len(matches))
+	assert.Equal("ro", matches[0].Category)
+	assert.Equal(3, matches[0].Index)
+	assert.Equal("longquery", matches[0].Host.Kind)
+}
+
+func TestFindHostAcrossRepos(t *testing.T) {
+	assert := assert.New(t)
+	printout := NewRepo("test/repos/host_tests/printout/")
+	repos := map[string]*Repo{
+		"printout": printout,
+		"copy":     printout,
+		"ssh":      NewRepo("test/sshdefaults/"),
+	}
+
+	assert.Equal(2, len(FindHost(repos, "db7.cluster3.company.net")))
+	assert.Equal(1, len(FindHost(repos, "web2.company.net")))
+	assert.Equal(0, len(FindHost(repos, "nowhere.company.net")))
+}
+
+func TestDirectHostUsesConfigDefaults(t *testing.T) {
+	assert := assert.New(t)
+	conf := &Config{SSHDefaults: SSHOptions{User: "deploy", Port: 2222}}
+
+	host := directHost(conf, "adhoc.company.net")
+
+	assert.Equal(
+		[]string{"ssh", "deploy@adhoc.company.net", "-A", "-t", "-p", "2222"},
+		host.command(),
+	)
+}
+
+func TestCopyIDCommand(t *testing.T) {
+	assert := assert.New(t)
+	host := &Host{FQDN: "web1.company.net", SSHOptions: SSHOptions{
+		User:    "deploy",
+		Port:    2222,
+		Jumps:   []string{"outer.company.net", "jump@bastion1.company.net"},
+		Options: []string{"StrictHostKeyChecking=no"},
+	}}
+
+	assert.Equal([]string{
+		"ssh-copy-id", "-i", "id_ed25519.pub", "-p", "2222",
+		"-o", "ProxyJump=outer.company.net,jump@bastion1.company.net",
+		"-o", "StrictHostKeyChecking=no",
+		"deploy@web1.company.net",
+	}, host.copyIDCommand("id_ed25519.pub"))
+
+	assert.Equal([]string{"ssh-copy-id", "db1.company.net"}, (&Host{FQDN: "db1.company.net"}).copyIDCommand(""))
+
+	host = &Host{FQDN: "db1.company.net", SSHOptions: SSHOptions{IdentityFile: "~/.ssh/ops"}}
+	assert.Equal([]string{
+		"ssh-copy-id", "-i", "~/.ssh/ops.pub",
+		"-o", "IdentityFile=~/.ssh/ops",
+		"db1.company.net",
+	}, host.copyIDCommand(""))
+	assert.Equal([]string{
+		"ssh-copy-id", "-i", "id_ed25519.pub",
+		"-o", "IdentityFile=~/.ssh/ops",
+		"db1.company.net",
+	}, host.copyIDCommand("id_ed25519.pub"))
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// countTargets are the things that can be counted, in the order they are printed
+var countTargets = []string{"items", "control", "subrepos", "hosts"}
+
+// Count is a tally of what a repository tree contains
+type Count struct {
+	Items    int
+	Control  int
+	Subrepos int
+	Hosts    int
+}
+
+// Add returns the sum of two counts
+func (c Count) Add(o Count) Count {
+	return Count{
+		Items:    c.Items + o.Items,
+		Control:  c.Control + o.Control,
+		Subrepos: c.Subrepos + o.Subrepos,
+		Hosts:    c.Hosts + o.Hosts,
+	}
+}
+
+// Get returns the number for one of the count targets
+func (c Count) Get(target string) (int, error) {
+	switch target {
+	case "items":
+		return c.Items, nil
+	case "control":
+		return c.Control, nil
+	case "subrepos":
+		return c.Subrepos, nil
+	case "hosts":
+		return c.Hosts, nil
+	}
+
+	return 0, fmt.Errorf(
+		"No such count target: %s. Choices are: %s",
+		target,
+		strings.Join(countTargets, ", "),
+	)
+}
+
+// CountRepo counts the contents of a repository without loading it
+//
+// The same files that NewRepo would load are visited, but only host files are
+// actually parsed since there is no other way of knowing how many hosts they
+// have. The key of the repository is returned along with the count.
+func CountRepo(p string) (string, Count) {
+	return countRepo(getPath(p), nil)
+}
+
+func countRepo(p string, parent *Repo) (string, Count) {
+	var c Count
+
+	// Only the settings are needed from the _repo.yaml, but the key is nice to
+	// have so that repos can be picked out by it.
+	r := Repo{Key: asKey(p), root: p, Parent: parent}
+	if data, err := repoFS.ReadFile(filepath.Join(p, "_repo.yaml")); err == nil {
+		yaml.Unmarshal(data, &r)
+	}
+	if parent != nil {
+		r.Settings = r.Settings.inherit(parent.Settings)
+	}
+
+	files, _ := repoFS.ReadDir(p)
+	for _, f := range files {
+		fn := filepath.Join(p, f.Name())
+		if strings.HasPrefix(f.Name(), ".") || r.Settings.ignored(f.Name()) {
+			continue
+		}
+
+		if f.IsDir() || (f.Mode()&os.ModeSymlink != 0 && isDir(fn) && r.follows(fn)) {
+			_, sub := countRepo(fn, &r)
+			c = c.Add(sub)
+			c.Subrepos++
+		} else if strings.HasSuffix(fn, ".yaml") || strings.HasSuffix(fn, ".md") {
+			if strings.HasPrefix(asKey(fn), "_") {
+				c.Control++
+			} else {
+				c.Items++
+				c.Hosts += countHosts(fn)
+			}
+		}
+	}
+
+	return r.Key, c
+}
+
+// countHosts returns the number of hosts in a file, or zero if it is not a
+// host file
+func countHosts(fn string) int {
+	data, err := repoFS.ReadFile(fn)
+	if err != nil {
+		return 0
+	}
+
+	var h HostInfo
+	yaml.Unmarshal(data, &h)
+	if h.Type() != "host" {
+		return 0
+	}
+
+	return len(h.Types.UniqueHosts())
+}
+
+// PrintCount prints the counts of all the configured repositories
+//
+// If a key is given, only the repo with that key is counted. If a target is
+// given, only that number is printed so that it can be used in scripts.
+func PrintCount(conf *Config, key, target string) {
+	var total Count
+	found := false
+
+	for _, dir := range conf.Repositories {
+		if _, err := repoFS.Stat(filepath.Join(dir, "_repo.yaml")); os.IsNotExist(err) {
+			continue
+		}
+
+		k, c := CountRepo(dir)
+		if key != "" && k != key {
+			continue
+		}
+
+		found = true
+		total = total.Add(c)
+	}
+
+	if key != "" && !found {
+		fmt.Println("No such repo:", key)
+		exit(exitNotFound)
+	}
+
+	if target != "" {
+		n, err := total.Get(target)
+		if err != nil {
+			fmt.Println(err)
+			exit(exitError)
+		}
+
+		fmt.Println(n)
+		return
+	}
+
+	for _, t := range countTargets {
+		n, _ := total.Get(t)
+		fmt.Printf("%s: %d\n", t, n)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCountRepoCountsNestedRepos(t *testing.T) {
+	assert := assert.New(t)
+
+	key, c := CountRepo("test/inherit/")
+
+	assert.Equal("inherit", key)
+	assert.Equal(1, c.Items) // The draft is ignored
+	assert.Equal(3, c.Control)
+	assert.Equal(2, c.Subrepos)
+	assert.Equal(0, c.Hosts)
+}
+
+func TestCountRepoCountsHosts(t *testing.T) {
+	assert := assert.New(t)
+
+	key, c := CountRepo("test/repos/host_tests/printout/")
+
+	assert.Equal("printout", key)
+	assert.Equal(1, c.Items)
+	assert.Equal(1, c.Subrepos)
+	assert.Equal(10, c.Hosts)
+}
+
+func TestCountRepoFromMemory(t *testing.T) {
+	assert := assert.New(t)
+	defer useFS(memFS{
+		"/mem/infra/_repo.yaml":       "summary: Infrastructure\n",
+		"/mem/infra/deploy.yaml":      "type: info\nbody: Push it\n",
+		"/mem/infra/dns/_repo.yaml":   "summary: DNS\n",
+		"/mem/infra/dns/zones.yaml":   "type: info\n",
+		"/mem/infra/dns/servers.yaml": "type: host\ntypes:\n  ns:\n    hosts:\n      - fqdn: ns1.company.net\n",
+	})()
+
+	key, c := CountRepo("/mem/infra")
+
+	assert.Equal("infra", key)
+	assert.Equal(Count{Items: 3, Control: 2, Subrepos: 1, Hosts: 1}, c)
+}
+
+func TestCountGetUnknownTarget(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Count{}.Get("articles")
+
+	assert.NotNil(err)
+}
+
+func ExamplePrintCount() {
+	conf := &Config{
+		Repositories: []string{"test/inherit", "test/repos/host_tests/printout"},
+	}
+
+	PrintCount(conf, "", "")
+	PrintCount(conf, "printout", "hosts")
+
+	// Output: items: 2
+	// control: 4
+	// subrepos: 3
+	// hosts: 10
+	// 10
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/fatih/color"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around changes
+const diffContext = 3
+
+// diffOp is one line of a diff. The kind is ' ' for lines in both, '-' for
+// lines only in the first and '+' for lines only in the second. a and b are the
+// indexes of the line in the first and second text.
+type diffOp struct {
+	kind byte
+	line string
+	a, b int
+}
+
+// diffLines returns the lines of a and b as a diff, using their longest common
+// subsequence
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i], i, j})
+			i++
+			j++
+		case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', a[i], i, j})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	return ops
+}
+
+// unifiedDiff returns the differences between two texts as a unified diff,
+// or nothing if they are the same
+func unifiedDiff(nameA, nameB, a, b string) string {
+	red := color.New(color.FgRed).SprintfFunc()
+	green := color.New(color.FgGreen).SprintfFunc()
+	cyan := color.New(color.FgCyan).SprintfFunc()
+	bold := color.New(color.Bold).SprintfFunc()
+
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var out bytes.Buffer
+	for start := 0; start < len(ops); {
+		first := -1
+		for x := start; x < len(ops); x++ {
+			if ops[x].kind != ' ' {
+				first = x
+				break
+			}
+		}
+		if first < 0 {
+			break
+		}
+
+		// Changes that are close enough to share their context end up in the
+		// same hunk.
+		last := first
+		for x := first; x < len(ops) && x-last <= 2*diffContext; x++ {
+			if ops[x].kind != ' ' {
+				last = x
+			}
+		}
+
+		from := first - diffContext
+		if from < start {
+			from = start
+		}
+		to := last + diffContext + 1
+		if to > len(ops) {
+			to = len(ops)
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintln(&out, bold("--- %s", nameA))
+			fmt.Fprintln(&out, bold("+++ %s", nameB))
+		}
+
+		hunk := ops[from:to]
+		countA, countB := 0, 0
+		for _, op := range hunk {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintln(&out, cyan("@@ -%s +%s @@", hunkRange(hunk[0].a, countA), hunkRange(hunk[0].b, countB)))
+
+		for _, op := range hunk {
+			switch op.kind {
+			case '-':
+				fmt.Fprintln(&out, red("-%s", op.line))
+			case '+':
+				fmt.Fprintln(&out, green("+%s", op.line))
+			default:
+				fmt.Fprintf(&out, " %s\n", op.line)
+			}
+		}
+
+		start = to
+	}
+
+	return out.String()
+}
+
+// hunkRange formats where a hunk is in one of the texts. Lines are counted
+// from one, but an empty range is given as the line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits a text into lines, without an empty line at the end
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// DiffItems prints a diff of the bodies of two items, given as paths of keys
+// separated by slashes, like `infra/runbooks/deploy`
+func DiffItems(repos map[string]*Repo, keyA, keyB string) {
+	bodies := make([]string, 2)
+	for x, key := range []string{keyA, keyB} {
+		item, err := findItem(repos, strings.Split(key, "/"))
+		if err != nil {
+			fmt.Printf("%s: %s\n", key, err)
+			exit(exitNotFound)
+		}
+
+		info, ok := item.(*Info)
+		if !ok {
+			fmt.Printf("%s: %s items have no body to compare\n", key, item.Type())
+			exit(exitError)
+		}
+		bodies[x] = info.Body
+	}
+
+	diff := unifiedDiff(keyA, keyB, bodies[0], bodies[1])
+	if diff == "" {
+		fmt.Println("No differences")
+		return
+	}
+	page(diff)
+}
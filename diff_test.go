@@ -0,0 +1,62 @@
+package main
+
+import (
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUnifiedDiffOfSameText(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("", unifiedDiff("a", "b", "one\ntwo\n", "one\ntwo\n"))
+}
+
+func TestUnifiedDiffFromNothing(t *testing.T) {
+	assert := assert.New(t)
+
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	assert.Equal("--- a\n+++ b\n@@ -0,0 +1,1 @@\n+new\n", unifiedDiff("a", "b", "", "new\n"))
+}
+
+func TestUnifiedDiffKeepsHunksApart(t *testing.T) {
+	assert := assert.New(t)
+
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n"
+
+	assert.Equal(
+		"--- a\n+++ b\n"+
+			"@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n"+
+			"@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		unifiedDiff("a", "b", a, b),
+	)
+}
+
+func ExampleDiffItems() {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	repos := map[string]*Repo{"diff": NewRepo("test/diff/")}
+
+	DiffItems(repos, "diff/deploy-prod", "diff/deploy-staging")
+	// Output:
+	// --- diff/deploy-prod
+	// +++ diff/deploy-staging
+	// @@ -2,6 +2,6 @@
+	//  2. Drain web1
+	//  3. Deploy to web1
+	//  4. Undrain web1
+	// -5. Repeat for every web host
+	// +5. Repeat for web2
+	//  6. Run the smoke tests
+	//  7. Announce that it is done
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/fatih/color"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"time"
+)
+
+// lookupHost resolves a host name. It is a variable so that tests can avoid
+// doing real DNS lookups.
+var lookupHost = net.DefaultResolver.LookupHost
+
+// Unresolvable returns the sorted FQDNs of the hosts that do not resolve
+//
+// All the lookups are done at the same time, and each of them gets at most
+// `timeout` to finish before it is considered failed.
+func (h HostInfo) Unresolvable(timeout time.Duration) []string {
+	// The same host can be in several categories, but there is no need to look
+	// it up more than once.
+	hosts := h.Types.UniqueHosts()
+
+	type result struct {
+		fqdn string
+		err  error
+	}
+
+	cr := make(chan result, len(hosts))
+	for _, host := range hosts {
+		go func(fqdn string) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			_, err := lookupHost(ctx, fqdn)
+			cr <- result{fqdn, err}
+		}(host.FQDN)
+	}
+
+	var failed []string
+	for x := 0; x < len(hosts); x++ {
+		if r := <-cr; r.err != nil {
+			failed = append(failed, r.fqdn)
+		}
+	}
+
+	sort.Strings(failed)
+	return failed
+}
+
+// ValidateHosts checks that all hosts in all repos resolve and reports the
+// ones that do not
+//
+// The process exits non-zero if any host failed to resolve.
+func ValidateHosts(repos map[string]*Repo, timeout time.Duration) {
+	blue := color.New(color.FgBlue, color.Bold).SprintfFunc()
+	red := color.New(color.FgRed, color.Bold).SprintfFunc()
+	green := color.New(color.FgGreen, color.Bold).SprintfFunc()
+
+	keys := make([]string, 0, len(repos))
+	for key := range repos {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	total, failed := 0, 0
+	for _, key := range keys {
+		for _, h := range repos[key].HostInfos() {
+			bad := h.Unresolvable(timeout)
+			total += len(h.Types.UniqueHosts())
+			failed += len(bad)
+
+			if len(bad) == 0 {
+				continue
+			}
+
+			fmt.Printf("%s:\n", blue(h.Path()))
+			for _, fqdn := range bad {
+				fmt.Printf("  %s\n", red(fqdn))
+			}
+			fmt.Println()
+		}
+	}
+
+	if failed != 0 {
+		fmt.Println(red(fmt.Sprintf("%d hosts did not resolve", failed)))
+		exit(exitError)
+	}
+
+	fmt.Println(green(fmt.Sprintf("All %d hosts resolve", total)))
+}
+
+// clearScreen moves the cursor to the top left and clears the terminal
+const clearScreen = "\x1b[H\x1b[2J"
+
+// statusTable checks every host in every repo and returns a table of whether
+// they resolve, along with the number of hosts that do not
+func statusTable(repos map[string]*Repo, timeout time.Duration) (string, int) {
+	blue := color.New(color.FgBlue, color.Bold).SprintfFunc()
+	red := color.New(color.FgRed, color.Bold).SprintfFunc()
+	green := color.New(color.FgGreen).SprintfFunc()
+
+	keys := make([]string, 0, len(repos))
+	for key := range repos {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var out bytes.Buffer
+	failed := 0
+	for _, key := range keys {
+		for _, h := range repos[key].HostInfos() {
+			bad := make(map[string]bool)
+			for _, fqdn := range h.Unresolvable(timeout) {
+				bad[fqdn] = true
+			}
+			failed += len(bad)
+
+			hosts := h.Types.UniqueHosts()
+			width := 0
+			for _, host := range hosts {
+				if len(host.FQDN) > width {
+					width = len(host.FQDN)
+				}
+			}
+
+			fmt.Fprintf(&out, "%s:\n", blue(h.Path()))
+			for _, host := range hosts {
+				status := green("ok")
+				if bad[host.FQDN] {
+					status = red("does not resolve")
+				}
+				fmt.Fprintf(&out, "  %-*s  %s\n", width, host.FQDN, status)
+			}
+			fmt.Fprintln(&out)
+		}
+	}
+
+	return out.String(), failed
+}
+
+// WatchHosts checks the hosts again and again until it is interrupted, like
+// running validate-hosts under watch(1)
+//
+// On a terminal the table is redrawn in place. Anywhere else, every check is
+// printed after the one before. The repositories are loaded again on SIGHUP,
+// so that hosts can be changed without starting over.
+func WatchHosts(set *RepoSet, timeout, interval time.Duration) {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	set.ReloadOnHangup(ctx.Done())
+	watchHosts(ctx, os.Stdout, isTerminal(os.Stdout), set, timeout, interval)
+}
+
+// watchHosts is WatchHosts, stopping when the context is cancelled
+func watchHosts(ctx context.Context, out io.Writer, tty bool, set *RepoSet, timeout, interval time.Duration) {
+	bold := color.New(color.Bold).SprintfFunc()
+
+	for {
+		table, failed := statusTable(set.Get(), timeout)
+
+		if tty {
+			fmt.Fprint(out, clearScreen)
+		}
+		fmt.Fprintln(out, bold(
+			"%s: every %s, %d hosts do not resolve",
+			time.Now().Format("15:04:05"), interval, failed,
+		))
+		fmt.Fprintln(out)
+		fmt.Fprint(out, table)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHostInfoUnresolvable(t *testing.T) {
+	assert := assert.New(t)
+	h := testHostInfo()
+
+	defer func(orig func(context.Context, string) ([]string, error)) {
+		lookupHost = orig
+	}(lookupHost)
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if strings.HasPrefix(host, "taskdb") {
+			return nil, errors.New("no such host")
+		}
+		return []string{"127.0.0.1"}, nil
+	}
+
+	bad := h.Unresolvable(time.Second)
+
+	assert.Equal([]string{
+		"taskdb1.cluster6.company.net",
+		"taskdb2.cluster6.company.net",
+	}, bad)
+}
+
+func TestHostInfoUnresolvableTimesOut(t *testing.T) {
+	assert := assert.New(t)
+	h := testHostInfo()
+
+	defer func(orig func(context.Context, string) ([]string, error)) {
+		lookupHost = orig
+	}(lookupHost)
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	bad := h.Unresolvable(time.Millisecond)
+
+	assert.Equal(10, len(bad))
+}
+
+func TestWatchHosts(t *testing.T) {
+	assert := assert.New(t)
+	set := NewRepoSet(nil, map[string]*Repo{"printout": NewRepo("test/repos/host_tests/printout/")})
+
+	defer func(orig func(context.Context, string) ([]string, error)) {
+		lookupHost = orig
+	}(lookupHost)
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if strings.HasPrefix(host, "taskdb") {
+			return nil, errors.New("no such host")
+		}
+		return []string{"127.0.0.1"}, nil
+	}
+
+	// A cancelled context stops the watch after the first check
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var out bytes.Buffer
+	watchHosts(ctx, &out, false, set, time.Second, time.Hour)
+
+	assert.Contains(out.String(), "2 hosts do not resolve")
+	assert.Contains(out.String(), "db1.cluster6.company.net")
+	assert.Contains(out.String(), "taskdb1.cluster6.company.net")
+	assert.NotContains(out.String(), clearScreen)
+
+	out.Reset()
+	watchHosts(ctx, &out, true, set, time.Second, time.Hour)
+	assert.True(strings.HasPrefix(out.String(), clearScreen))
+}
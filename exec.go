@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// splitCommand splits the arguments at the first --, into the ones before it
+// and the command after it. ok is false if there is no --.
+func splitCommand(args []string) (before, command []string, ok bool) {
+	for x, arg := range args {
+		if arg == "--" {
+			return args[:x], args[x+1:], true
+		}
+	}
+	return args, nil, false
+}
+
+// resolveSelector finds the host that a selector points at
+//
+// A selector is either the FQDN of a host anywhere in the repos, or the path
+// to a category like infra/hosts/web, which means its primary host. The path
+// can end with the index or FQDN of a host in the category, like
+// infra/hosts/web/2, or with a query that is matched against the FQDNs and
+// summaries like when connecting.
+func resolveSelector(repos map[string]*Repo, selector string, interactive bool) (*Host, error) {
+	if !strings.Contains(selector, "/") {
+		matches := FindHost(repos, selector)
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("No host %s in any repo", selector)
+		}
+		return &matches[0].Host, nil
+	}
+
+	keys := strings.Split(selector, "/")
+	r, err := findRepo(repos, keys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	item, remaining, err := r.GetItem(keys[1:])
+	if err != nil {
+		return nil, err
+	}
+	h, ok := item.(*HostInfo)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a host file", item.ID())
+	}
+
+	if len(remaining) == 0 || len(remaining) > 2 {
+		return nil, fmt.Errorf("Give a category of %s, and maybe a host in it. Categories are: %s", item.ID(), strings.Join(h.Types.List(), ", "))
+	}
+	cat, ok := h.Types[remaining[0]]
+	if !ok {
+		return nil, fmt.Errorf("No such category: %s. Choices are: %s", remaining[0], strings.Join(h.Types.List(), ", "))
+	}
+	if len(cat.Hosts) == 0 {
+		return nil, fmt.Errorf("There are no hosts in %s", remaining[0])
+	}
+
+	if len(remaining) == 1 {
+		return cat.PrimaryHost(), nil
+	}
+
+	query := remaining[1]
+	if _, err := strconv.Atoi(query); err == nil || cat.GetHost(query) != nil {
+		hosts, err := cat.Select([]string{query})
+		if err != nil {
+			return nil, err
+		}
+		return &hosts[0], nil
+	}
+	return pickHost(query, cat.Match(query), interactive)
+}
+
+// ExecSelector runs a command on the host that a selector points at, like
+// `sagacity exec infra/hosts/web/2 -- uptime`
+//
+// Everything after the -- is the command, flags and all, so there is no
+// mixing up which arguments pick the host and which are run on it.
+func ExecSelector(repos map[string]*Repo, args []string) {
+	before, command, ok := splitCommand(args)
+	if !ok || len(before) != 1 || len(command) == 0 {
+		fmt.Println("Give a host and a command separated by --, like infra/hosts/web/2 -- uptime")
+		exit(exitError)
+	}
+
+	host, err := resolveSelector(repos, before[0], isTerminal(os.Stdin) && isTerminal(os.Stdout))
+	if err != nil {
+		fmt.Println(err)
+		exit(exitNotFound)
+	}
+
+	exitOnFailure(host.Execute(command...))
+}
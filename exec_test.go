@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	before, command, ok := splitCommand([]string{"infra/hosts/web", "--", "ls", "-la", "--", "x"})
+	assert.True(ok)
+	assert.Equal([]string{"infra/hosts/web"}, before)
+	assert.Equal([]string{"ls", "-la", "--", "x"}, command)
+
+	_, _, ok = splitCommand([]string{"infra/hosts/web", "ls"})
+	assert.False(ok)
+}
+
+func TestResolveSelector(t *testing.T) {
+	assert := assert.New(t)
+	repos := map[string]*Repo{"infra": NewRepo("test/kinds/")}
+
+	for selector, fqdn := range map[string]string{
+		"infra/hosts/web":                  "web1.company.net",
+		"infra/hosts/web/1":                "web2.company.net",
+		"infra/hosts/web/web2.company.net": "web2.company.net",
+		"infra/hosts/web/web2":             "web2.company.net",
+		"db1.company.net":                  "db1.company.net",
+	} {
+		host, err := resolveSelector(repos, selector, false)
+		assert.Nil(err, selector)
+		assert.Equal(fqdn, host.FQDN, selector)
+	}
+
+	for selector, msg := range map[string]string{
+		"infra/hosts/web/company": "company is ambiguous",
+		"infra/hosts/web/5":       "No host with index 5",
+		"infra/hosts/cache":       "No such category: cache",
+		"infra/hosts":             "Give a category of hosts",
+		"nowhere.company.net":     "No host nowhere.company.net in any repo",
+	} {
+		_, err := resolveSelector(repos, selector, false)
+		assert.Contains(err.Error(), msg, selector)
+	}
+}
+
+func TestExecSelectorPassesTheCommandThrough(t *testing.T) {
+	assert := assert.New(t)
+	f := &fakeRunner{}
+	defer useRunner(f)()
+	repos := map[string]*Repo{"infra": NewRepo("test/kinds/")}
+
+	ExecSelector(repos, []string{"infra/hosts/db", "--", "ls", "-la", "--color=never", "my dir"})
+
+	assert.Equal(
+		[][]string{{"ssh", "db1.company.net", "-A", "ls -la --color=never 'my dir'"}},
+		f.calls,
+	)
+}
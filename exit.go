@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Exit codes, so that scripts can tell failures apart. Interrupts that are not
+// handled kill the process with SIGINT, which shells also report as 130.
+const (
+	// exitError is for bad arguments and anything else that went wrong
+	exitError = 1
+
+	// exitNotFound is for a repo, item, category, section or host that does
+	// not exist
+	exitNotFound = 2
+
+	// exitGitError is for git commands that failed
+	exitGitError = 3
+
+	// exitInterrupted is for sessions and commands that were interrupted
+	exitInterrupted = 130
+)
+
+// exit ends the process with a code. Tests replace it to see which code a
+// failure exits with.
+var exit = os.Exit
+
+// errInterrupted is what a session that was interrupted fails with
+var errInterrupted = errors.New("interrupted")
+
+// exitOnFailure exits if a session or command failed, with exitInterrupted if
+// it was interrupted and exitError otherwise
+func exitOnFailure(err error) {
+	if err == nil {
+		return
+	}
+
+	fmt.Println(err)
+	if err == errInterrupted {
+		exit(exitInterrupted)
+	}
+	exit(exitError)
+}
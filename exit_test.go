@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/codegangsta/cli"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// exited is what the exit of exitCode panics with, so that the code after it
+// does not run
+type exited int
+
+// exitCode runs f and returns the code that it exits with, or -1 if it does
+// not exit
+func exitCode(f func()) (code int) {
+	defer func(orig func(int)) { exit = orig }(exit)
+	exit = func(code int) { panic(exited(code)) }
+
+	defer func() {
+		if r := recover(); r != nil {
+			c, ok := r.(exited)
+			if !ok {
+				panic(r)
+			}
+			code = int(c)
+		}
+	}()
+
+	f()
+	return -1
+}
+
+// runCLI runs the command line of a repo with some arguments
+func runCLI(r *Repo, args ...string) {
+	app := cli.NewApp()
+	app.Commands = []cli.Command{r.MakeCLI()}
+	app.Run(append([]string{"sagacity", r.Key}, args...))
+}
+
+// interruptRunner is interrupted while the command runs
+type interruptRunner struct{}
+
+func (interruptRunner) Run(ctx context.Context, args, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	syscall.Kill(os.Getpid(), syscall.SIGINT)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestExitCodeNotFound(t *testing.T) {
+	assert := assert.New(t)
+	repos := map[string]*Repo{"order": NewRepo("test/order/")}
+	hosts := NewRepo("test/kinds/")
+
+	assert.Equal(exitNotFound, exitCode(func() { CatItem(repos, []string{"order", "missing"}) }))
+	assert.Equal(exitNotFound, exitCode(func() { OpenRepo(repos, "missing", false) }))
+	assert.Equal(exitNotFound, exitCode(func() { runCLI(repos["order"], "missing") }))
+	assert.Equal(exitNotFound, exitCode(func() { runCLI(hosts, "hosts", "missing") }))
+	assert.Equal(exitNotFound, exitCode(func() { hosts.GetHost("missing primary") }))
+	assert.Equal(exitNotFound, exitCode(func() { NewRepo("test/nowhere/") }))
+}
+
+func TestExitCodeUserError(t *testing.T) {
+	assert := assert.New(t)
+	repos := map[string]*Repo{"order": NewRepo("test/order/")}
+
+	assert.Equal(exitError, exitCode(func() { ShowItem(repos, []string{"order", "drain"}, "toml") }))
+	assert.Equal(exitError, exitCode(func() { RenameItem(repos, "order/drain", "../elsewhere") }))
+	assert.Equal(exitError, SyncManifest(&Config{}, "test/missing-manifest.yaml"))
+}
+
+func TestExitCodeGitError(t *testing.T) {
+	assert := assert.New(t)
+	defer func(orig string) { gitBinary = orig }(gitBinary)
+	gitBinary = "false"
+
+	assert.Equal(exitGitError, exitCode(func() { git("", "status") }))
+}
+
+func TestExitCodeSSH(t *testing.T) {
+	assert := assert.New(t)
+	host := &Host{FQDN: "web1.company.net"}
+
+	// Execute only returns the failure, so that one host cannot end a run
+	restore := useRunner(&fakeRunner{err: errors.New("exit status 255")})
+	err := host.Execute()
+	assert.EqualError(err, "ssh command failed: exit status 255")
+	assert.Equal(exitError, exitCode(func() { exitOnFailure(err) }))
+	restore()
+
+	defer useRunner(interruptRunner{})()
+	err = host.Execute()
+	assert.Equal(errInterrupted, err)
+	assert.Equal(exitInterrupted, exitCode(func() { exitOnFailure(err) }))
+	assert.Equal(-1, exitCode(func() { exitOnFailure(nil) }))
+}
+
+func TestExitCodeFanOut(t *testing.T) {
+	assert := assert.New(t)
+	host := Host{FQDN: "web1.company.net"}
+
+	assert.Equal(-1, exitCode(func() { reportFanOut([]Result{{Host: host}}, false) }))
+	assert.Equal(exitError, exitCode(func() { reportFanOut([]Result{{Host: host, Err: errSkipped}}, false) }))
+	assert.Equal(exitInterrupted, exitCode(func() {
+		reportFanOut([]Result{{Host: host, Err: errCancelled}, {Host: host, Err: errSkipped}}, true)
+	}))
+}
+
+func TestExitCodeNoExit(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(-1, exitCode(func() {}))
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/codegangsta/cli"
+	"github.com/fatih/color"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+)
+
+// errSkipped is the result of hosts that were never run, because of
+// --fail-fast or an interrupt
+var errSkipped = errors.New("skipped")
+
+// errCancelled is the result of hosts that were stopped while running, because
+// of --fail-fast or an interrupt
+var errCancelled = errors.New("cancelled")
+
+// Result is the outcome of running a command on one host
+type Result struct {
+	Host Host
+	Err  error
+}
+
+// FanOutOptions changes how FanOut runs the commands
+type FanOutOptions struct {
+	// OutputDir is where the output of each host is written, as <fqdn>.log.
+	// If it is empty, the output goes to stdout.
+	OutputDir string
+
+	// Parallel is the largest number of hosts to run on at the same time. If
+	// it is zero, all of them are run at once.
+	Parallel int
+
+	// FailFast stops everything as soon as one host fails. The hosts still
+	// running are cancelled, and the ones not yet started are skipped.
+	FailFast bool
+
+	// OnlyErrors only shows the output of the hosts that failed. It is held
+	// back until each host is done, since there is no knowing before then.
+	OnlyErrors bool
+}
+
+// fanOutFlags are the flags of every command that runs on several hosts
+var fanOutFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "output",
+		Usage: "write the output of each host to <output>/<fqdn>.log",
+	},
+	cli.IntFlag{
+		Name:  "parallel",
+		Usage: "run on at most this many hosts at the same time (default all)",
+	},
+	cli.BoolFlag{
+		Name:  "fail-fast",
+		Usage: "stop all hosts as soon as one of them fails",
+	},
+	cli.BoolFlag{
+		Name:  "only-errors",
+		Usage: "only show the output of hosts that fail",
+	},
+}
+
+// fanOutOptions creates the options from the flags of a command
+func fanOutOptions(c *cli.Context) FanOutOptions {
+	return FanOutOptions{
+		OutputDir:  c.String("output"),
+		Parallel:   c.Int("parallel"),
+		FailFast:   c.Bool("fail-fast"),
+		OnlyErrors: c.Bool("only-errors"),
+	}
+}
+
+// runOnHost runs a command on a host. It is a variable so that tests can avoid
+// actually connecting anywhere.
+var runOnHost = (*Host).run
+
+// FanOut runs commands on several hosts at the same time
+//
+// commands[x] is run on hosts[x]. Every line of output is prefixed with the
+// FQDN of the host it came from, so that the interleaved output can be told
+// apart, unless it is written to files. The results are in the same order as
+// the hosts.
+//
+// An interrupt (Ctrl-C) stops the hosts that are running and skips the rest,
+// so that the results of the ones that were done can still be reported. It
+// also returns whether that happened.
+func FanOut(hosts []Host, commands []string, opts FanOutOptions) ([]Result, bool) {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	results := fanOut(ctx, hosts, commands, opts)
+	return results, ctx.Err() != nil
+}
+
+// fanOut is FanOut, stopping when the context is cancelled
+func fanOut(parent context.Context, hosts []Host, commands []string, opts FanOutOptions) []Result {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	results := make([]Result, len(hosts))
+	if opts.OutputDir != "" {
+		if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+			for x, host := range hosts {
+				results[x] = Result{Host: host, Err: err}
+			}
+			return results
+		}
+	}
+
+	workers := opts.Parallel
+	if workers <= 0 || workers > len(hosts) {
+		workers = len(hosts)
+	}
+
+	jobs := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for x := range jobs {
+				host := hosts[x]
+				if ctx.Err() != nil {
+					results[x] = Result{Host: host, Err: errSkipped}
+					continue
+				}
+
+				var err error
+				switch {
+				case opts.OutputDir != "":
+					err = runToFile(ctx, &host, commands[x], opts.OutputDir)
+
+				case opts.OnlyErrors:
+					var buf bytes.Buffer
+					out := &prefixWriter{prefix: host.FQDN + ": ", out: &buf, mu: &sync.Mutex{}}
+					err = runOnHost(&host, ctx, out, commands[x])
+					out.Flush()
+
+					if err != nil {
+						mu.Lock()
+						buf.WriteTo(os.Stdout)
+						mu.Unlock()
+					}
+
+				default:
+					out := &prefixWriter{prefix: host.FQDN + ": ", out: os.Stdout, mu: &mu}
+					err = runOnHost(&host, ctx, out, commands[x])
+					out.Flush()
+				}
+
+				if err != nil && ctx.Err() != nil {
+					err = errCancelled
+				} else if err != nil && opts.FailFast {
+					cancel()
+				}
+
+				results[x] = Result{Host: host, Err: err}
+			}
+		}()
+	}
+
+	for x := range hosts {
+		jobs <- x
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	if opts.OutputDir != "" {
+		fmt.Println("Wrote the output of each host to", opts.OutputDir)
+	}
+	return results
+}
+
+// runToFile runs a command on the host with the output going to a file named
+// after the host in dir
+func runToFile(ctx context.Context, host *Host, command, dir string) error {
+	f, err := os.Create(filepath.Join(dir, host.FQDN+".log"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return runOnHost(host, ctx, f, command)
+}
+
+// ExecuteTemplate renders the command template for every host and runs them
+// all, exiting non-zero if anything went wrong
+func ExecuteTemplate(hosts []Host, tmpl string, opts FanOutOptions) {
+	commands, err := renderCommands(tmpl, hosts)
+	if err != nil {
+		fmt.Println(err)
+		exit(exitError)
+	}
+
+	reportFanOut(FanOut(hosts, commands, opts))
+}
+
+// ExecuteCommand runs the same command on every host, exiting non-zero if
+// anything went wrong
+//
+// Unlike ExecuteTemplate, the command is sent as it is, so that anything like
+// `{{.State}}` in it reaches the hosts untouched.
+func ExecuteCommand(hosts []Host, command string, opts FanOutOptions) {
+	commands := make([]string, len(hosts))
+	for x := range commands {
+		commands[x] = command
+	}
+
+	reportFanOut(FanOut(hosts, commands, opts))
+}
+
+// reportFanOut prints the summary of a fan-out, and exits with exitInterrupted
+// if it was interrupted or exitError if any host failed
+func reportFanOut(results []Result, interrupted bool) {
+	failed := printSummary(results)
+	switch {
+	case interrupted:
+		exit(exitInterrupted)
+	case failed != 0:
+		exit(exitError)
+	}
+}
+
+// renderCommands renders the template once for every host
+//
+// The host is the data of the template, so `{{.FQDN}}`, `{{.Kind}}` and
+// `{{.Summary}}` are all available. Every command is rendered before anything
+// is run, so that a broken template never leaves the hosts half done.
+func renderCommands(tmpl string, hosts []Host) ([]string, error) {
+	t, err := template.New("exec").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("Bad command template: %s", err)
+	}
+
+	commands := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, host); err != nil {
+			return nil, fmt.Errorf("Bad command template for %s: %s", host.FQDN, err)
+		}
+		commands = append(commands, buf.String())
+	}
+
+	return commands, nil
+}
+
+// printSummary prints how a fan-out went and returns the number of failures
+func printSummary(results []Result) int {
+	red := color.New(color.FgRed, color.Bold).SprintfFunc()
+	green := color.New(color.FgGreen, color.Bold).SprintfFunc()
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+
+	fmt.Println()
+	if failed == 0 {
+		fmt.Println(green(fmt.Sprintf("All %d hosts succeeded", len(results))))
+		return 0
+	}
+
+	fmt.Println(red(fmt.Sprintf("%d of %d hosts failed:", failed, len(results))))
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("  %s: %s\n", red(r.Host.FQDN), r.Err)
+		}
+	}
+
+	return failed
+}
+
+// run runs a command on the host without any input, sending all of the output
+// to out. The secrets of the host are resolved first, like for Execute.
+func (h *Host) run(ctx context.Context, out io.Writer, command string) error {
+	args, err := h.connectCommand(command)
+	if err != nil {
+		return err
+	}
+	env, err := h.env()
+	if err != nil {
+		return err
+	}
+	return sshRunner.Run(ctx, args, env, nil, out, out)
+}
+
+// prefixWriter prefixes every line written to it before passing it on
+//
+// Several writers can share the same output as long as they share the mutex
+// as well, since whole lines are always written at once.
+type prefixWriter struct {
+	prefix string
+	out    io.Writer
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		x := bytes.IndexByte(w.buf, '\n')
+		if x < 0 {
+			break
+		}
+
+		w.writeLine(w.buf[:x])
+		w.buf = w.buf[x+1:]
+	}
+
+	return len(p), nil
+}
+
+// Flush writes anything that is left without a trailing newline
+func (w *prefixWriter) Flush() {
+	if len(w.buf) != 0 {
+		w.writeLine(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fmt.Fprintf(w.out, "%s%s\n", w.prefix, line)
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// fakeRun replaces runOnHost, returning a function that restores it
+func fakeRun(run func(h *Host, ctx context.Context, out io.Writer, command string) error) func() {
+	orig := runOnHost
+	runOnHost = run
+	return func() { runOnHost = orig }
+}
+
+func TestRenderCommandsPerHost(t *testing.T) {
+	assert := assert.New(t)
+	hosts := []Host{
+		{FQDN: "web1.company.net", Kind: "frontend"},
+		{FQDN: "web2.company.net", Kind: "canary"},
+	}
+
+	cmds, err := renderCommands("curl http://{{.FQDN}}/health?kind={{.Kind}}", hosts)
+
+	assert.Nil(err)
+	assert.Equal([]string{
+		"curl http://web1.company.net/health?kind=frontend",
+		"curl http://web2.company.net/health?kind=canary",
+	}, cmds)
+}
+
+func TestRenderCommandsBadTemplate(t *testing.T) {
+	assert := assert.New(t)
+	hosts := []Host{{FQDN: "web1.company.net"}}
+
+	_, err := renderCommands("echo {{.FQDN", hosts)
+	assert.NotNil(err)
+
+	_, err = renderCommands("echo {{.Hostname}}", hosts)
+	assert.NotNil(err)
+}
+
+func TestPrefixWriterPrefixesEveryLine(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	w := &prefixWriter{prefix: "db1: ", out: &buf, mu: &sync.Mutex{}}
+
+	w.Write([]byte("one\ntw"))
+	w.Write([]byte("o\nthree"))
+	w.Flush()
+
+	assert.Equal("db1: one\ndb1: two\ndb1: three\n", buf.String())
+}
+
+func TestFanOutWritesOneFilePerHost(t *testing.T) {
+	assert := assert.New(t)
+	defer fakeRun(func(h *Host, ctx context.Context, out io.Writer, command string) error {
+		fmt.Fprintf(out, "%s ran %s\n", h.FQDN, command)
+		return nil
+	})()
+
+	dir, _ := ioutil.TempDir("", "sagacity")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "results")
+
+	hosts := []Host{{FQDN: "web1.company.net"}, {FQDN: "web2.company.net"}}
+	results, _ := FanOut(hosts, []string{"uptime", "df"}, FanOutOptions{OutputDir: out})
+
+	assert.Nil(results[0].Err)
+	assert.Nil(results[1].Err)
+
+	files, _ := ioutil.ReadDir(out)
+	assert.Equal(2, len(files))
+
+	data, _ := ioutil.ReadFile(filepath.Join(out, "web2.company.net.log"))
+	assert.Equal("web2.company.net ran df\n", string(data))
+}
+
+func TestFanOutFailFastSkipsLaterHosts(t *testing.T) {
+	assert := assert.New(t)
+
+	var ran []string
+	defer fakeRun(func(h *Host, ctx context.Context, out io.Writer, command string) error {
+		ran = append(ran, h.FQDN)
+		if h.FQDN == "web1.company.net" {
+			return errors.New("exit status 1")
+		}
+		return nil
+	})()
+
+	hosts := []Host{{FQDN: "web1.company.net"}, {FQDN: "web2.company.net"}, {FQDN: "web3.company.net"}}
+	results, interrupted := FanOut(hosts, []string{"a", "b", "c"}, FanOutOptions{Parallel: 1, FailFast: true})
+
+	assert.Equal([]string{"web1.company.net"}, ran)
+	assert.EqualError(results[0].Err, "exit status 1")
+	assert.Equal(errSkipped, results[1].Err)
+	assert.Equal(errSkipped, results[2].Err)
+
+	// Hosts skipped by --fail-fast are not an interrupt
+	assert.False(interrupted)
+}
+
+func TestFanOutFailFastCancelsRunningHosts(t *testing.T) {
+	assert := assert.New(t)
+
+	defer fakeRun(func(h *Host, ctx context.Context, out io.Writer, command string) error {
+		if h.FQDN == "web1.company.net" {
+			return errors.New("exit status 1")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})()
+
+	hosts := []Host{{FQDN: "web1.company.net"}, {FQDN: "web2.company.net"}}
+	results, _ := FanOut(hosts, []string{"a", "b"}, FanOutOptions{FailFast: true})
+
+	assert.EqualError(results[0].Err, "exit status 1")
+	assert.Equal(errCancelled, results[1].Err)
+}
+
+func TestFanOutWithoutFailFastRunsEveryHost(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	ran := 0
+	defer fakeRun(func(h *Host, ctx context.Context, out io.Writer, command string) error {
+		mu.Lock()
+		ran++
+		mu.Unlock()
+		return errors.New("exit status 1")
+	})()
+
+	hosts := []Host{{FQDN: "web1.company.net"}, {FQDN: "web2.company.net"}, {FQDN: "web3.company.net"}}
+	FanOut(hosts, []string{"a", "b", "c"}, FanOutOptions{Parallel: 2})
+
+	assert.Equal(3, ran)
+}
+
+func ExampleFanOut_onlyErrors() {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	defer fakeRun(func(h *Host, ctx context.Context, out io.Writer, command string) error {
+		fmt.Fprintf(out, "ran %s\n", command)
+		if h.FQDN == "web2.company.net" {
+			return errors.New("exit status 1")
+		}
+		return nil
+	})()
+
+	hosts := []Host{{FQDN: "web1.company.net"}, {FQDN: "web2.company.net"}, {FQDN: "web3.company.net"}}
+	results, _ := FanOut(hosts, []string{"a", "b", "c"}, FanOutOptions{OnlyErrors: true})
+	printSummary(results)
+
+	// Output:
+	// web2.company.net: ran b
+	//
+	// 1 of 3 hosts failed:
+	//   web2.company.net: exit status 1
+}
+
+func TestFanOutCancelStopsPendingHosts(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var ran []string
+	defer fakeRun(func(h *Host, ctx context.Context, out io.Writer, command string) error {
+		ran = append(ran, h.FQDN)
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	})()
+
+	hosts := []Host{{FQDN: "web1.company.net"}, {FQDN: "web2.company.net"}, {FQDN: "web3.company.net"}}
+	results := fanOut(ctx, hosts, []string{"a", "b", "c"}, FanOutOptions{Parallel: 1})
+
+	assert.Equal([]string{"web1.company.net"}, ran)
+	assert.Equal(errCancelled, results[0].Err)
+	assert.Equal(errSkipped, results[1].Err)
+	assert.Equal(errSkipped, results[2].Err)
+}
+
+func TestCategoryExecuteAll(t *testing.T) {
+	assert := assert.New(t)
+	var mu sync.Mutex
+	ran := make(map[string]string)
+	defer fakeRun(func(h *Host, ctx context.Context, out io.Writer, command string) error {
+		mu.Lock()
+		ran[h.FQDN] = command
+		mu.Unlock()
+		if h.FQDN == "web2.company.net" {
+			return errors.New("unreachable")
+		}
+		return nil
+	})()
+
+	cat := Category{Hosts: []Host{{FQDN: "web1.company.net"}, {FQDN: "web2.company.net"}, {FQDN: "web3.company.net"}}}
+	code := exitCode(func() { cat.ExecuteAll(FanOutOptions{}, "df", "-h /") })
+
+	// One unreachable host fails the run, but the others still get to run
+	assert.Equal(exitError, code)
+	assert.Equal(map[string]string{
+		"web1.company.net": "df '-h /'",
+		"web2.company.net": "df '-h /'",
+		"web3.company.net": "df '-h /'",
+	}, ran)
+}
+
+func TestExecuteCommandIsNotATemplate(t *testing.T) {
+	assert := assert.New(t)
+	var mu sync.Mutex
+	var ran []string
+	defer fakeRun(func(h *Host, ctx context.Context, out io.Writer, command string) error {
+		mu.Lock()
+		ran = append(ran, command)
+		mu.Unlock()
+		return nil
+	})()
+
+	hosts := []Host{{FQDN: "web1.company.net"}, {FQDN: "web2.company.net"}}
+	code := exitCode(func() { ExecuteCommand(hosts, "docker inspect --format '{{.State}}' web", FanOutOptions{}) })
+
+	assert.Equal(-1, code)
+	assert.Equal([]string{
+		"docker inspect --format '{{.State}}' web",
+		"docker inspect --format '{{.State}}' web",
+	}, ran)
+}
+
+func TestExecutePrimariesSendsTheCommandAsItIs(t *testing.T) {
+	assert := assert.New(t)
+	var mu sync.Mutex
+	ran := make(map[string]string)
+	defer fakeRun(func(h *Host, ctx context.Context, out io.Writer, command string) error {
+		mu.Lock()
+		ran[h.FQDN] = command
+		mu.Unlock()
+		return nil
+	})()
+
+	h := NewRepo("test/kinds/").Items["hosts"].(*HostInfo)
+	h.Types.ExecutePrimaries([]string{"echo {{.FQDN}}"}, FanOutOptions{})
+
+	assert.Equal(map[string]string{
+		"db1.company.net":  "echo {{.FQDN}}",
+		"web1.company.net": "echo {{.FQDN}}",
+	}, ran)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"github.com/codegangsta/cli"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter decides which items are shown in listings and search results
+type Filter struct {
+	Since time.Time
+
+	// MatchType only lets through items of that type, if it is set
+	MatchType string
+
+	// ExcludeTypes and ExcludeTags remove the items with any of them, even
+	// if they match everything else
+	ExcludeTypes []string
+	ExcludeTags  []string
+
+	// IncludeArchived shows archived and deprecated items, which are hidden
+	// otherwise
+	IncludeArchived bool
+}
+
+// filterFlags are the flags of every command that filters items
+var filterFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "since",
+		Usage: "only items modified since a duration ago (7d, 12h) or a date (2006-01-02)",
+	},
+	cli.StringFlag{
+		Name:  "match-type",
+		Usage: "only items of this type",
+	},
+	cli.StringSliceFlag{
+		Name:  "exclude",
+		Usage: "hide items with type=<type> or tag=<tag>, can be repeated",
+		Value: &cli.StringSlice{},
+	},
+	cli.BoolFlag{
+		Name:  "include-archived",
+		Usage: "show archived and deprecated items as well",
+	},
+}
+
+// tagger is implemented by items that can be tagged
+type tagger interface {
+	Tags() []string
+}
+
+// archiver is implemented by items that can be archived
+type archiver interface {
+	Archived() bool
+}
+
+// isArchived returns true if the item is archived or deprecated
+func isArchived(item Item) bool {
+	a, ok := item.(archiver)
+	return ok && a.Archived()
+}
+
+// newFilter creates a filter from the flags of a command
+func newFilter(c *cli.Context) (f Filter, err error) {
+	f.IncludeArchived = c.Bool("include-archived")
+	f.MatchType = c.String("match-type")
+
+	if since := c.String("since"); since != "" {
+		f.Since, err = parseSince(since, time.Now())
+		if err != nil {
+			return
+		}
+	}
+
+	for _, ex := range c.StringSlice("exclude") {
+		if err = f.exclude(ex); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// exclude adds a type=<type> or tag=<tag> exclusion to the filter
+func (f *Filter) exclude(s string) error {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return fmt.Errorf("Cannot parse exclude %q, expected type=<type> or tag=<tag>", s)
+	}
+
+	switch parts[0] {
+	case "type":
+		f.ExcludeTypes = append(f.ExcludeTypes, parts[1])
+	case "tag":
+		f.ExcludeTags = append(f.ExcludeTags, parts[1])
+	default:
+		return fmt.Errorf("Cannot exclude by %q, only by type or tag", parts[0])
+	}
+
+	return nil
+}
+
+// Match returns true if the item passes the filter
+//
+// Items without a known modification time never match a --since filter.
+// Archived items never match unless they are asked for.
+func (f Filter) Match(item Item) bool {
+	if !f.IncludeArchived && isArchived(item) {
+		return false
+	}
+
+	if !f.Since.IsZero() {
+		mtime := item.ModTime()
+		if mtime.IsZero() || mtime.Before(f.Since) {
+			return false
+		}
+	}
+
+	if f.MatchType != "" && item.Type() != f.MatchType {
+		return false
+	}
+
+	for _, t := range f.ExcludeTypes {
+		if item.Type() == t {
+			return false
+		}
+	}
+
+	if tg, ok := item.(tagger); ok && len(f.ExcludeTags) != 0 {
+		for _, tag := range tg.Tags() {
+			for _, ex := range f.ExcludeTags {
+				if tag == ex {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+// sinceRxp matches the day and week durations that time.ParseDuration lacks
+var sinceRxp = regexp.MustCompile(`^(\d+)([dw])$`)
+
+// parseSince parses either a duration back in time from now, or a date
+//
+// Durations are anything time.ParseDuration understands, plus days (7d) and
+// weeks (2w). Dates are either 2006-01-02 or full RFC 3339 timestamps.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if m := sinceRxp.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		days := n
+		if m[2] == "w" {
+			days = n * 7
+		}
+		return now.AddDate(0, 0, -days), nil
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Cannot parse %q as a duration or a date", s)
+}
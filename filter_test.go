@@ -0,0 +1,119 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var filterNow = time.Date(2016, 3, 15, 12, 0, 0, 0, time.Local)
+
+func TestParseSinceDurations(t *testing.T) {
+	assert := assert.New(t)
+
+	for s, expected := range map[string]time.Time{
+		"7d":  time.Date(2016, 3, 8, 12, 0, 0, 0, time.Local),
+		"2w":  time.Date(2016, 3, 1, 12, 0, 0, 0, time.Local),
+		"36h": time.Date(2016, 3, 14, 0, 0, 0, 0, time.Local),
+		"90m": time.Date(2016, 3, 15, 10, 30, 0, 0, time.Local),
+	} {
+		since, err := parseSince(s, filterNow)
+		assert.Nil(err, s)
+		assert.True(expected.Equal(since), s)
+	}
+}
+
+func TestParseSinceDates(t *testing.T) {
+	assert := assert.New(t)
+
+	since, err := parseSince("2016-02-29", filterNow)
+	assert.Nil(err)
+	assert.True(time.Date(2016, 2, 29, 0, 0, 0, 0, time.Local).Equal(since))
+
+	since, err = parseSince("2016-02-29T08:00:00Z", filterNow)
+	assert.Nil(err)
+	assert.True(time.Date(2016, 2, 29, 8, 0, 0, 0, time.UTC).Equal(since))
+}
+
+func TestParseSinceGarbage(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := parseSince("last tuesday", filterNow)
+	assert.NotNil(err)
+}
+
+func TestFilterSince(t *testing.T) {
+	assert := assert.New(t)
+	f := Filter{Since: filterNow.AddDate(0, 0, -7)}
+
+	assert.True(f.Match(Info{mtime: filterNow.AddDate(0, 0, -1)}))
+	assert.False(f.Match(Info{mtime: filterNow.AddDate(0, 0, -8)}))
+
+	// Unknown modification times never match
+	assert.False(f.Match(Info{}))
+
+	// An empty filter matches everything
+	assert.True(Filter{}.Match(Info{}))
+}
+
+func TestFilterExclude(t *testing.T) {
+	assert := assert.New(t)
+
+	var f Filter
+	assert.Nil(f.exclude("type=note"))
+	assert.Nil(f.exclude("tag=archived"))
+
+	archived := Info{RawType: "info", Extra: map[string]interface{}{
+		"tags": []interface{}{"db", "archived"},
+	}}
+
+	assert.False(f.Match(Info{RawType: "note"}))
+	assert.False(f.Match(archived))
+	assert.True(f.Match(Info{RawType: "info"}))
+}
+
+func TestFilterExcludeWithSince(t *testing.T) {
+	assert := assert.New(t)
+	f := Filter{Since: filterNow.AddDate(0, 0, -7), ExcludeTypes: []string{"url"}}
+
+	assert.True(f.Match(Info{RawType: "info", mtime: filterNow}))
+	assert.False(f.Match(Info{RawType: "url", mtime: filterNow}))
+	assert.False(f.Match(Info{RawType: "info", mtime: filterNow.AddDate(0, 0, -8)}))
+}
+
+func TestFilterExcludeGarbage(t *testing.T) {
+	assert := assert.New(t)
+	var f Filter
+
+	assert.NotNil(f.exclude("archived"))
+	assert.NotNil(f.exclude("owner=payments"))
+	assert.NotNil(f.exclude("tag="))
+}
+
+func TestFilterArchived(t *testing.T) {
+	assert := assert.New(t)
+
+	archived := Info{Extra: map[string]interface{}{"archived": true}}
+	deprecated := Info{Extra: map[string]interface{}{"status": "deprecated"}}
+
+	assert.False(Filter{}.Match(archived))
+	assert.False(Filter{}.Match(deprecated))
+	assert.True(Filter{IncludeArchived: true}.Match(archived))
+	assert.True(Filter{IncludeArchived: true}.Match(deprecated))
+}
+
+func TestFilterMatchTypeWithExclude(t *testing.T) {
+	assert := assert.New(t)
+
+	var f Filter
+	f.MatchType = "runbook"
+	assert.Nil(f.exclude("tag=archived"))
+
+	tagged := Info{RawType: "runbook", Extra: map[string]interface{}{
+		"tags": []interface{}{"archived"},
+	}}
+
+	assert.True(f.Match(Info{RawType: "runbook"}))
+	assert.False(f.Match(Info{RawType: "note"}))
+	assert.False(f.Match(tagged))
+}
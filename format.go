@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// formatFile runs the formatter of the repository on a file that is about to
+// be saved, and returns true if it changed the file
+//
+// The formatter is a command such as `yamlfmt` or `prettier --write`, which is
+// given the path of the file and is expected to rewrite it in place. Nothing
+// happens if the repo has no formatter, or if the formatter is not installed.
+func (r *Repo) formatFile(path string) (bool, error) {
+	args := strings.Fields(r.Settings.Formatter)
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	if _, err := exec.LookPath(args[0]); err != nil {
+		log.Printf("Not formatting %s: %s is not installed", path, args[0])
+		return false, nil
+	}
+
+	before, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	out, err := exec.Command(args[0], append(args[1:], path)...).CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf(
+			"formatting %s failed (%s): %s",
+			path, err, strings.TrimSpace(string(out)),
+		)
+	}
+
+	after, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	return !bytes.Equal(before, after), nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// formatTarget writes a file to format into a temporary directory, returning
+// its path and a function that removes it again
+func formatTarget(content string) (string, func()) {
+	dir, _ := ioutil.TempDir("", "sagacity")
+	fn := filepath.Join(dir, "item.yaml")
+	ioutil.WriteFile(fn, []byte(content), 0644)
+
+	return fn, func() { os.RemoveAll(dir) }
+}
+
+func TestFormatFileReportsChanges(t *testing.T) {
+	assert := assert.New(t)
+	fn, done := formatTarget("type: Info\n")
+	defer done()
+
+	r := &Repo{Settings: Settings{Formatter: "sed -i s/Info/info/"}}
+	changed, err := r.formatFile(fn)
+
+	assert.Nil(err)
+	assert.True(changed)
+	data, _ := ioutil.ReadFile(fn)
+	assert.Equal("type: info\n", string(data))
+
+	changed, err = r.formatFile(fn)
+	assert.Nil(err)
+	assert.False(changed)
+}
+
+func TestFormatFileSkipsMissingFormatter(t *testing.T) {
+	assert := assert.New(t)
+	fn, done := formatTarget("type:    info\n")
+	defer done()
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	r := &Repo{Settings: Settings{Formatter: "sagacity-no-such-formatter"}}
+	changed, err := r.formatFile(fn)
+
+	assert.Nil(err)
+	assert.False(changed)
+}
+
+func TestFormatFileWithoutFormatter(t *testing.T) {
+	assert := assert.New(t)
+
+	changed, err := (&Repo{}).formatFile("does/not/exist.yaml")
+
+	assert.Nil(err)
+	assert.False(changed)
+}
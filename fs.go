@@ -0,0 +1,24 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// A FileSystem is where repositories are loaded from
+type FileSystem interface {
+	ReadDir(dir string) ([]os.FileInfo, error)
+	ReadFile(fn string) ([]byte, error)
+	Stat(fn string) (os.FileInfo, error)
+}
+
+// osFS is the real file system
+type osFS struct{}
+
+func (osFS) ReadDir(dir string) ([]os.FileInfo, error) { return ioutil.ReadDir(dir) }
+func (osFS) ReadFile(fn string) ([]byte, error)        { return ioutil.ReadFile(fn) }
+func (osFS) Stat(fn string) (os.FileInfo, error)       { return os.Stat(fn) }
+
+// repoFS is the file system that repositories and their items are loaded
+// from. It can be replaced to load them from somewhere else, like memory.
+var repoFS FileSystem = osFS{}
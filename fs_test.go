@@ -0,0 +1,119 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memFS is a file system in memory, mapping file paths to their contents.
+// Directories are implied by the files in them.
+type memFS map[string]string
+
+type memFile struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (f memFile) Name() string       { return f.name }
+func (f memFile) Size() int64        { return f.size }
+func (f memFile) ModTime() time.Time { return time.Time{} }
+func (f memFile) IsDir() bool        { return f.dir }
+func (f memFile) Sys() interface{}   { return nil }
+func (f memFile) Mode() os.FileMode {
+	if f.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (m memFS) ReadDir(dir string) ([]os.FileInfo, error) {
+	seen := make(map[string]bool)
+	var files []os.FileInfo
+
+	for fn := range m {
+		rel, err := filepath.Rel(dir, fn)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		name := strings.Split(rel, string(filepath.Separator))[0]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		fi, _ := m.Stat(filepath.Join(dir, name))
+		files = append(files, fi)
+	}
+
+	return files, nil
+}
+
+func (m memFS) ReadFile(fn string) ([]byte, error) {
+	data, ok := m[fn]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: fn, Err: os.ErrNotExist}
+	}
+	return []byte(data), nil
+}
+
+func (m memFS) Stat(fn string) (os.FileInfo, error) {
+	if data, ok := m[fn]; ok {
+		return memFile{filepath.Base(fn), int64(len(data)), false}, nil
+	}
+
+	for path := range m {
+		if strings.HasPrefix(path, fn+string(filepath.Separator)) {
+			return memFile{filepath.Base(fn), 0, true}, nil
+		}
+	}
+
+	return nil, &os.PathError{Op: "stat", Path: fn, Err: os.ErrNotExist}
+}
+
+// useFS replaces repoFS, returning a function that restores it
+func useFS(fs FileSystem) func() {
+	orig := repoFS
+	repoFS = fs
+	return func() { repoFS = orig }
+}
+
+func TestNewRepoFromMemory(t *testing.T) {
+	assert := assert.New(t)
+	defer useFS(memFS{
+		"/mem/infra/_repo.yaml":          "summary: Infrastructure\n",
+		"/mem/infra/deploy.yaml":         "type: info\nbody: Push it\n",
+		"/mem/infra/dns/_repo.yaml":      "",
+		"/mem/infra/dns/zones.yaml":      "type: info\nbody: All of them\n",
+		"/mem/infra/dns/.hidden.yaml":    "type: info\n",
+		"/mem/infra/dns/records/a.yaml":  "type: info\n",
+		"/mem/infra/dns/records/b.draft": "not yaml\n",
+	})()
+
+	r := NewRepo("/mem/infra")
+
+	assert.Equal("Infrastructure", r.Summary)
+	assert.Equal([]string{"deploy"}, r.Keys())
+	assert.Equal([]string{"dns"}, r.SubrepoKeys())
+	assert.Equal([]string{"zones"}, r.Subrepos["dns"].Keys())
+	assert.Equal([]string{"a"}, r.Subrepos["dns"].Subrepos["records"].Keys())
+	assert.Equal("All of them", r.Subrepos["dns"].Items["zones"].(*Info).Body)
+}
+
+func TestLoadReposFromMemorySkipsNonRepos(t *testing.T) {
+	assert := assert.New(t)
+	defer useFS(memFS{
+		"/mem/infra/_repo.yaml": "key: infra\n",
+		"/mem/junk/notes.yaml":  "type: info\n",
+	})()
+
+	repos := LoadRepos(&Config{Repositories: []string{"/mem/infra", "/mem/junk"}, Quiet: true})
+
+	assert.Equal(1, len(repos))
+	assert.NotNil(repos["infra"])
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// footersEnabled is true if the footer hooks of repos may run. Like post_load
+// hooks, they are only run when the configuration says `hooks: true`.
+var footersEnabled = false
+
+// runHooks runs the post_load hooks of the repository and all of its subrepos,
+// and returns the errors of the ones that failed
+//
+// Hooks are shell commands, so they can do anything the user can. They are
+// only run if the configuration asks for it.
+func (r *Repo) runHooks() (errs []error) {
+	if err := r.runHook(); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, key := range r.SubrepoKeys() {
+		sub, _ := r.Subrepo(key)
+		errs = append(errs, sub.runHooks()...)
+	}
+
+	return
+}
+
+// runHook runs the post_load hook of the repository, if it has one
+//
+// The hook is run in the repository directory, with its root and key in the
+// environment as SAGACITY_REPO_ROOT and SAGACITY_REPO_KEY.
+func (r *Repo) runHook() error {
+	if r.PostLoad == "" {
+		return nil
+	}
+
+	cmd := exec.Command("sh", "-c", r.PostLoad)
+	cmd.Dir = r.root
+	cmd.Env = append(
+		os.Environ(),
+		"SAGACITY_REPO_ROOT="+r.root,
+		"SAGACITY_REPO_KEY="+r.Key,
+	)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf(
+			"post_load hook of %s failed (%s): %s",
+			r.root, err, strings.TrimSpace(string(out)),
+		)
+	}
+
+	return nil
+}
+
+// footer runs the footer hook of the repo of an item and returns its output,
+// to be shown after the item
+//
+// The hook is run in the repository directory with the path of the item as its
+// first argument and as SAGACITY_ITEM_PATH. If it fails, the failure is logged
+// and nothing is added, so that the item itself is still shown.
+func (i Info) footer() string {
+	if !footersEnabled || i.repo == nil || i.repo.Settings.Footer == "" {
+		return ""
+	}
+
+	cmd := exec.Command("sh", "-c", i.repo.Settings.Footer, "sh", i.Path())
+	cmd.Dir = i.repo.root
+	cmd.Env = append(
+		os.Environ(),
+		"SAGACITY_REPO_ROOT="+i.repo.root,
+		"SAGACITY_REPO_KEY="+i.repo.Key,
+		"SAGACITY_ITEM_PATH="+i.Path(),
+	)
+
+	out, err := cmd.Output()
+	if err != nil {
+		log.Printf("footer hook of %s failed for %s: %s", i.repo.root, i.ID(), err)
+		return ""
+	}
+
+	if len(out) == 0 {
+		return ""
+	}
+	return "\n" + strings.TrimRight(string(out), "\n") + "\n"
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, _ := ioutil.TempDir("", "sagacity")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "hook.out")
+	os.Setenv("HOOK_OUT", out)
+	defer os.Unsetenv("HOOK_OUT")
+
+	errs := NewRepo("test/hooks/").runHooks()
+
+	data, _ := ioutil.ReadFile(out)
+	assert.Equal("hooks in hooks\n", string(data))
+
+	assert.Equal(1, len(errs))
+	assert.Contains(errs[0].Error(), "exit status 3")
+	assert.Contains(errs[0].Error(), "cannot reach the inventory")
+}
+
+func TestNoHook(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(NewRepo("test/order/").runHook())
+}
+
+func TestFooter(t *testing.T) {
+	assert := assert.New(t)
+	dir, cleanup := writeRepo(map[string]string{
+		"_repo.yaml":       "footer: echo \"reviewed $(basename \"$1\") in $SAGACITY_REPO_KEY\"\n",
+		"runbook.yaml":     "type: info\nbody: Do it\n",
+		"bad/_repo.yaml":   "footer: exit 1\n",
+		"bad/runbook.yaml": "type: info\nbody: Do it\n",
+	})
+	defer cleanup()
+	r := NewRepo(dir)
+	info := r.Items["runbook"].(*Info)
+	bad := r.Subrepos["bad"].Items["runbook"].(*Info)
+
+	assert.Equal("", info.footer())
+
+	footersEnabled = true
+	defer func() { footersEnabled = false }()
+
+	assert.Equal("\nreviewed runbook.yaml in "+r.Key+"\n", info.footer())
+	assert.Equal("", bad.footer())
+}
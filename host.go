@@ -1,25 +1,32 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/codegangsta/cli"
 	"github.com/fatih/color"
 	text "github.com/tonnerre/golang-text"
+	"io"
 	"log"
 	"os"
-	"os/exec"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"text/template"
+	"time"
 )
 
 // A HostInfo is a YAML file with information about a group of hosts
 type HostInfo struct {
-	RawType    string   `yaml:"type"`
-	RawSummary string   `yaml:"summary"`
-	Types      HostType `yaml:"types"`
+	RawType    string     `yaml:"type"`
+	RawSummary string     `yaml:"summary,omitempty"`
+	Defaults   SSHOptions `yaml:"defaults,omitempty"`
+	Types      HostType   `yaml:"types"`
+	cycle      []string
 	id         string
 	path       string
+	mtime      time.Time
 	repo       *Repo
 }
 
@@ -28,17 +35,101 @@ type HostType map[string]Category
 
 // Category defines a set categories of machines
 type Category struct {
-	Summary string `yaml:"summary"`
-	Primary bool   `yaml:"primary"`
-	Hosts   []Host `yaml:"hosts"`
+	Summary    string `yaml:"summary,omitempty"`
+	Primary    bool   `yaml:"primary,omitempty"`
+	Hosts      []Host `yaml:"hosts,omitempty"`
+	Kind       string `yaml:"kind,omitempty"`
+	SSHOptions `yaml:",inline"`
 }
 
 // Host is a representation of one host
+//
+// Hosts are reached with ssh, unless they have a `command`. That is a template
+// like the ones given to --exec-template, and it is run locally instead. The
+// `secrets` of a host are resolved when it is connected to, and are put in the
+// environment of ssh or the command. ssh is told to send them on with SendEnv.
 type Host struct {
-	FQDN    string `yaml:"fqdn"`
-	Summary string `yaml:"summary"`
-	Kind    string `yaml:"kind"`
-	Primary bool   `yaml:"primary"`
+	FQDN       string            `yaml:"fqdn,omitempty"`
+	Summary    string            `yaml:"summary,omitempty"`
+	Kind       string            `yaml:"kind,omitempty"`
+	Primary    bool              `yaml:"primary,omitempty"`
+	TTY        bool              `yaml:"tty,omitempty"`
+	Tags       []string          `yaml:"tags,omitempty"`
+	Env        string            `yaml:"env,omitempty"`
+	Command    string            `yaml:"command,omitempty"`
+	Secrets    map[string]string `yaml:"secrets,omitempty"`
+	SSHOptions `yaml:",inline"`
+
+	// category and index are where the host is in its file, for telling
+	// the user which host was picked
+	category string
+	index    int
+}
+
+// SSHOptions are the connection settings handed to ssh
+//
+// They can be set on a host, on a category, as the `defaults` of a host file
+// and as the `ssh_defaults` of a repo. Every field is resolved on its own, and
+// the most specific definition wins:
+//
+//	host > category > file > repo
+//
+// A chain of bastions is given as `jumps`, which replaces `jump`. A jump can
+// also be the name of a category in the same host file, which means its
+// primary host.
+type SSHOptions struct {
+	User         string   `yaml:"user,omitempty"`
+	Port         int      `yaml:"port,omitempty"`
+	IdentityFile string   `yaml:"identity_file,omitempty"`
+	Jump         string   `yaml:"jump,omitempty"`
+	Jumps        []string `yaml:"jumps,omitempty"`
+	Options      []string `yaml:"options,omitempty"`
+
+	// KeepAlive is the ServerAliveInterval in seconds, and KeepAliveCount
+	// the ServerAliveCountMax. They are left to ssh unless set.
+	KeepAlive      int `yaml:"keepalive,omitempty"`
+	KeepAliveCount int `yaml:"keepalive_count,omitempty"`
+}
+
+// keepAliveOptions returns the ssh -o options for the keepalive, if any
+func (o SSHOptions) keepAliveOptions() (opts []string) {
+	if o.KeepAlive != 0 {
+		opts = append(opts, fmt.Sprintf("ServerAliveInterval=%d", o.KeepAlive))
+	}
+	if o.KeepAliveCount != 0 {
+		opts = append(opts, fmt.Sprintf("ServerAliveCountMax=%d", o.KeepAliveCount))
+	}
+	return
+}
+
+// merge returns a copy of the options with anything unset taken from parent
+func (o SSHOptions) merge(parent SSHOptions) SSHOptions {
+	if o.User == "" {
+		o.User = parent.User
+	}
+	if o.Port == 0 {
+		o.Port = parent.Port
+	}
+	if o.IdentityFile == "" {
+		o.IdentityFile = parent.IdentityFile
+	}
+	if o.Jump == "" {
+		o.Jump = parent.Jump
+	}
+	if o.Jumps == nil {
+		o.Jumps = parent.Jumps
+	}
+	if o.Options == nil {
+		o.Options = parent.Options
+	}
+	if o.KeepAlive == 0 {
+		o.KeepAlive = parent.KeepAlive
+	}
+	if o.KeepAliveCount == 0 {
+		o.KeepAliveCount = parent.KeepAliveCount
+	}
+
+	return o
 }
 
 func (h HostInfo) String() string {
@@ -46,31 +137,73 @@ func (h HostInfo) String() string {
 }
 
 // Execute opens a ssh connection to the specified host
+//
+// Without arguments, that is the primary host of the primary category. If no
+// category is primary, or if --list is given, the hosts are listed instead.
+//
+// If the first argument is a selector like `@frontend` (a tag) or `@env:prod`
+// (an environment), the rest of the arguments are run as a command on every
+// selected host. Without a command, the selected hosts are just listed.
+//
+// With --primaries, the arguments are run as a command on the primary host of
+// every category instead.
 func (h HostInfo) Execute(c *cli.Context) {
 	args := c.Args()
 	arglen := len(args)
 
+	if c.Bool("primaries") {
+		h.Types.ExecutePrimaries(args, fanOutOptions(c))
+		return
+	}
+
+	if arglen != 0 && strings.HasPrefix(args[0], "@") {
+		hosts, err := h.Types.HostsBySelector(args[0][1:])
+		if err != nil {
+			fmt.Println(err)
+			exit(exitNotFound)
+		}
+
+		if arglen == 1 {
+			for _, host := range hosts {
+				fmt.Println(host.FQDN)
+			}
+			return
+		}
+
+		ExecuteCommand(hosts, remoteCommand(args[1:])[0], fanOutOptions(c))
+		return
+	}
+
 	switch arglen {
 	case 0:
 		// No further arguments - we have selected a host entry but no type.
-		// Print the list of Types.
-		h.Types.PrintType()
+		// Go to the primary host of the primary category if there is one,
+		// and otherwise print the list of Types.
+		if host := h.Types.PrimaryHost(); host != nil && !c.Bool("list") {
+			exitOnFailure(host.Execute())
+			return
+		}
+		h.Types.PrintType(PrintOptions{
+			PrimaryFirst: c.Bool("primary-first"),
+			SummaryOnly:  c.Bool("summary-only"),
+			ShowKind:     c.Bool("show-kind"),
+			Cols:         outputCols(),
+		})
 
 	case 1, 2:
 		t := args[0]
 		if cat, ok := h.Types[t]; ok {
 			if arglen == 1 {
 				// One argument, go to the primary of that category
-				cat.PrimaryHost().Execute("")
+				cat.executePrimary(t)
 			} else {
-				// Two arguments, go to specified host
-				x, err := strconv.Atoi(args[1])
+				// Two arguments, go to the host with that index or FQDN
+				host, err := cat.Lookup(args[1])
 				if err != nil {
-					log.Fatal("Non-integer argument:", args[1])
+					fmt.Println(err)
+					exit(exitNotFound)
 				}
-
-				host := cat.Hosts[x]
-				host.Execute("")
+				exitOnFailure(host.Execute())
 			}
 
 		} else {
@@ -78,11 +211,90 @@ func (h HostInfo) Execute(c *cli.Context) {
 			fmt.Println(
 				fmt.Sprintf("Choices are: %s", strings.Join(h.Types.List(), ", ")),
 			)
-			os.Exit(1)
+			exit(exitNotFound)
+		}
+
+	default:
+		fmt.Println("Too many arguments. Give a type and the index of a host in it")
+		exit(exitError)
+	}
+}
+
+// validate checks that the jumps do not go in circles, and that the command
+// templates of the hosts can be used
+func (h *HostInfo) validate() error {
+	if h.cycle != nil {
+		return fmt.Errorf("Jump cycle in %s: %s", h.path, strings.Join(h.cycle, " -> "))
+	}
+
+	for _, host := range h.Types.Hosts() {
+		if host.Command == "" {
+			continue
+		}
+		if _, err := template.New("command").Parse(host.Command); err != nil {
+			return fmt.Errorf("Bad command template for %s in %s: %s", host.FQDN, h.path, err)
+		}
+	}
+	return nil
+}
+
+// resolve merges the SSH options of every level down into the hosts, so that
+// each host ends up with the options that apply to it. Hosts without a kind
+// get the kind of their category.
+func (h *HostInfo) resolve(repo SSHOptions) {
+	defaults := h.Defaults.merge(repo)
+
+	for key, cat := range h.Types {
+		opts := cat.SSHOptions.merge(defaults)
+		for x := range cat.Hosts {
+			cat.Hosts[x].category, cat.Hosts[x].index = key, x
+			cat.Hosts[x].SSHOptions = cat.Hosts[x].SSHOptions.merge(opts)
+			if cat.Hosts[x].Kind == "" {
+				cat.Hosts[x].Kind = cat.Kind
+			}
+		}
+		h.Types[key] = cat
+	}
+
+	// Jumps can only be resolved once every host has its options, since they
+	// refer to the other hosts. Cycles have to be found before that, while the
+	// jumps still say which category they refer to.
+	h.cycle = h.Types.jumpCycle()
+	for _, cat := range h.Types {
+		for x := range cat.Hosts {
+			o := &cat.Hosts[x].SSHOptions
+			o.Jump = h.Types.jumpHost(o.Jump)
+
+			jumps := make([]string, len(o.Jumps))
+			for y, jump := range o.Jumps {
+				jumps[y] = h.Types.jumpHost(jump)
+			}
+			if o.Jumps != nil {
+				o.Jumps = jumps
+			}
 		}
 	}
 }
 
+// jumpHost returns what to give to ssh -J for a jump. If it is the name of a
+// category, that is the primary host of it, along with its user and port.
+func (h HostType) jumpHost(jump string) string {
+	cat, ok := h[jump]
+	if !ok || len(cat.Hosts) == 0 {
+		return jump
+	}
+
+	host := cat.PrimaryHost()
+	spec := host.FQDN
+	if host.User != "" {
+		spec = host.User + "@" + spec
+	}
+	if host.Port != 0 {
+		spec += ":" + strconv.Itoa(host.Port)
+	}
+	return spec
+}
+
 // ID returns the ID of the item
 func (h HostInfo) ID() string {
 	return h.id
@@ -98,45 +310,168 @@ func (h HostInfo) Path() string {
 	return h.path
 }
 
+// ModTime returns the modification time of the item's file
+func (h HostInfo) ModTime() time.Time {
+	return h.mtime
+}
+
 // Summary returns the summary of the item
 func (h HostInfo) Summary() string {
 	return h.RawSummary
 }
 
+// Flags returns the flags of the host file command, which are --list and the
+// ones used when running commands on tagged hosts
+func (h HostInfo) Flags() []cli.Flag {
+	return append([]cli.Flag{
+		cli.BoolFlag{
+			Name:  "list",
+			Usage: "list the hosts even if there is a primary category",
+		},
+		cli.BoolFlag{
+			Name:  "primary-first",
+			Usage: "list the primary category before the others",
+		},
+		cli.BoolFlag{
+			Name:  "show-kind",
+			Usage: "show the kind of every host",
+		},
+		cli.BoolFlag{
+			Name:  "summary-only",
+			Usage: "list only the categories and their summaries, not the hosts",
+		},
+		cli.BoolFlag{
+			Name:  "primaries",
+			Usage: "run a command on (or open panes to) the primary host of every category",
+		},
+	}, fanOutFlags...)
+}
+
 // MakeCLI creates the CLI tree for a Host info
 func (h HostInfo) MakeCLI() []cli.Command {
 	sc := make([]cli.Command, 0, len(h.Types))
 	for _, key := range h.Types.List() {
-		cat := h.Types[key]
+		key, cat := key, h.Types[key]
 		cc := cli.Command{ // cc = category command
 			Name:        key,
 			Usage:       cat.Summary,
 			HideHelp:    true,
 			Subcommands: make([]cli.Command, 0, len(cat.Hosts)),
+			Flags: append([]cli.Flag{
+				cli.BoolFlag{
+					Name:  "panes",
+					Usage: "open the hosts given (or all of them) in split tmux panes",
+				},
+				cli.BoolFlag{
+					Name:  "first",
+					Usage: "connect to the first host instead of the primary",
+				},
+				cli.BoolFlag{
+					Name:  "last",
+					Usage: "connect to the last host instead of the primary",
+				},
+				cli.BoolFlag{
+					Name:  "print-ssh",
+					Usage: "print the ssh command of every host instead of connecting",
+				},
+				cli.StringFlag{
+					Name:  "exec-template",
+					Usage: "run a command on the hosts given (or all of them), with {{.FQDN}} etc. filled in per host",
+				},
+				cli.BoolFlag{
+					Name:  "all",
+					Usage: "run the command given on every host at the same time",
+				},
+			}, fanOutFlags...),
 			Action: func(c *cli.Context) {
-				cat.PrimaryHost().Execute("")
+				if c.Bool("all") {
+					cat.ExecuteAll(fanOutOptions(c), c.Args()...)
+					return
+				}
+
+				if tmpl := c.String("exec-template"); tmpl != "" {
+					hosts, err := cat.Select(c.Args())
+					if err != nil {
+						fmt.Println(err)
+						exit(exitError)
+					}
+
+					ExecuteTemplate(hosts, tmpl, fanOutOptions(c))
+					return
+				}
+
+				if c.Bool("print-ssh") {
+					page(cat.SSHCommands())
+					return
+				}
+
+				if c.Bool("panes") {
+					hosts, err := cat.Select(c.Args())
+					if err == nil {
+						err = OpenPanes(hosts)
+					}
+					if err != nil {
+						fmt.Println(err)
+						exit(exitError)
+					}
+					return
+				}
+
+				if c.Bool("first") || c.Bool("last") {
+					pick := cat.First
+					if c.Bool("last") {
+						pick = cat.Last
+					}
+
+					host, err := pick()
+					if err != nil {
+						fmt.Println(err)
+						exit(exitNotFound)
+					}
+					exitOnFailure(host.Execute())
+					return
+				}
+
+				// Indexes are the ones shown when listing, like [1]. Anything
+				// that is not an index or the FQDN of a host is matched
+				// against the FQDNs and summaries instead.
+				if query := c.Args().First(); query != "" {
+					host, err := cat.Lookup(query)
+					if _, ok := err.(indexError); !ok && err != nil {
+						host, err = pickHost(query, cat.Match(query), isTerminal(os.Stdin) && isTerminal(os.Stdout))
+					}
+					if err != nil {
+						fmt.Println(err)
+						exit(exitNotFound)
+					}
+					exitOnFailure(host.Execute())
+					return
+				}
+
+				cat.executePrimary(key)
 			},
 		}
 
 		for _, host := range cat.Hosts {
+			fqdn := host.FQDN
 			hc := cli.Command{ // hc = host command
 				Name:     host.FQDN,
 				Usage:    host.Summary,
 				HideHelp: true,
 				Action: func(c *cli.Context) {
-					var host *Host
-					args := c.Args()
-
-					if len(args) == 0 {
-						// No extra arguments - go to the primary host
-						host = cat.PrimaryHost()
-					} else {
-						// Arguments were defined - go to the fqdn specified
-						// TODO(thiderman): Error handling, integer index handling
-						host = cat.GetHost(args[0])
+					// Without arguments, go to the host of the command.
+					// Otherwise go to the host given by index or FQDN.
+					arg := fqdn
+					if len(c.Args()) != 0 {
+						arg = c.Args()[0]
 					}
 
-					host.Execute("")
+					host, err := cat.Lookup(arg)
+					if err != nil {
+						fmt.Println(err)
+						exit(exitNotFound)
+					}
+					exitOnFailure(host.Execute())
 				},
 			}
 			cc.Subcommands = append(cc.Subcommands, hc)
@@ -156,7 +491,8 @@ func (h HostInfo) getHosts() (Types []string) {
 	return
 }
 
-// PrimaryHost returns the primary host inside of the HostInfo
+// PrimaryHost returns the primary host inside of the HostInfo, or nil if the
+// category has no hosts
 func (c *Category) PrimaryHost() (h *Host) {
 	for _, host := range c.Hosts {
 		if host.Primary {
@@ -166,9 +502,39 @@ func (c *Category) PrimaryHost() (h *Host) {
 	}
 
 	// No primary was found, just pick the first one
+	if len(c.Hosts) == 0 {
+		return nil
+	}
 	return &c.Hosts[0]
 }
 
+// executePrimary connects to the primary host of the category called key,
+// exiting if it has no hosts
+func (c *Category) executePrimary(key string) {
+	host := c.PrimaryHost()
+	if host == nil {
+		fmt.Println("There are no hosts in", key)
+		exit(exitNotFound)
+	}
+	exitOnFailure(host.Execute())
+}
+
+// First returns the first host of the category
+func (c *Category) First() (*Host, error) {
+	if len(c.Hosts) == 0 {
+		return nil, fmt.Errorf("No hosts in the category")
+	}
+	return &c.Hosts[0], nil
+}
+
+// Last returns the last host of the category
+func (c *Category) Last() (*Host, error) {
+	if len(c.Hosts) == 0 {
+		return nil, fmt.Errorf("No hosts in the category")
+	}
+	return &c.Hosts[len(c.Hosts)-1], nil
+}
+
 // GetHost returns a specific host, based on FQDN
 func (c *Category) GetHost(fqdn string) (h *Host) {
 	for _, host := range c.Hosts {
@@ -179,6 +545,155 @@ func (c *Category) GetHost(fqdn string) (h *Host) {
 	return
 }
 
+// indexError is what Lookup fails with for an index that is out of range
+type indexError struct {
+	index int
+	hosts int
+}
+
+func (e indexError) Error() string {
+	return fmt.Sprintf("No host with index %d. Choices are 0 to %d", e.index, e.hosts-1)
+}
+
+// Lookup returns the host with an index, like the [1] shown when listing, or
+// with an FQDN
+//
+// The error lists the indexes and FQDNs that there are, if it is neither.
+func (c *Category) Lookup(arg string) (*Host, error) {
+	if len(c.Hosts) == 0 {
+		return nil, fmt.Errorf("No hosts in the category")
+	}
+
+	if x, err := strconv.Atoi(arg); err == nil {
+		if x < 0 || x >= len(c.Hosts) {
+			return nil, indexError{x, len(c.Hosts)}
+		}
+		return &c.Hosts[x], nil
+	}
+
+	if host := c.GetHost(arg); host != nil {
+		return host, nil
+	}
+
+	fqdns := make([]string, len(c.Hosts))
+	for x, host := range c.Hosts {
+		fqdns[x] = host.FQDN
+	}
+	return nil, fmt.Errorf("No such host: %s. Choices are 0 to %d or %s", arg, len(c.Hosts)-1, strings.Join(fqdns, ", "))
+}
+
+// Match returns the hosts whose FQDN or summary contains the query
+func (c *Category) Match(query string) (hosts []Host) {
+	for _, host := range c.Hosts {
+		if strings.Contains(host.FQDN, query) || strings.Contains(host.Summary, query) {
+			hosts = append(hosts, host)
+		}
+	}
+	return
+}
+
+// pickHost narrows down the hosts that a query matched to one
+//
+// If there are several, the user gets to choose one when interactive is set.
+// Otherwise they are all listed in the error, so that scripts fail instead of
+// waiting for an answer that never comes.
+func pickHost(query string, hosts []Host, interactive bool) (*Host, error) {
+	switch len(hosts) {
+	case 0:
+		return nil, fmt.Errorf("No host matches %s", query)
+	case 1:
+		return &hosts[0], nil
+	}
+
+	if !interactive {
+		fqdns := make([]string, len(hosts))
+		for x, host := range hosts {
+			fqdns[x] = host.FQDN
+		}
+		return nil, fmt.Errorf("%s is ambiguous. Choices are: %s", query, strings.Join(fqdns, ", "))
+	}
+
+	yellow := color.New(color.FgYellow).SprintfFunc()
+	hiyellow := color.New(color.FgHiYellow, color.Bold).SprintfFunc()
+
+	fmt.Printf("%s matches several hosts:\n", query)
+	for x, host := range hosts {
+		fmt.Printf("  %s%s%s %s\n", yellow("["), hiyellow("%d", x), yellow("]"), host.FQDN)
+	}
+
+	x, ok := choose(fmt.Sprintf("Which one? [0-%d] ", len(hosts)-1), len(hosts))
+	if !ok {
+		return nil, fmt.Errorf("Doing nothing.")
+	}
+	return &hosts[x], nil
+}
+
+// Select returns the hosts matching the arguments, or all hosts if there are no
+// arguments. The arguments can be either indexes or FQDNs.
+func (c *Category) Select(args []string) ([]Host, error) {
+	if len(args) == 0 {
+		return c.Hosts, nil
+	}
+
+	hosts := make([]Host, 0, len(args))
+	for _, arg := range args {
+		host, err := c.Lookup(arg)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, *host)
+	}
+
+	return hosts, nil
+}
+
+// ExecuteAll runs the same command on every host in the category at the same
+// time, with each line of output prefixed by the FQDN of its host
+//
+// A host that fails does not stop the others, unless opts says fail fast. The
+// failures are summed up at the end, and the process exits non-zero if there
+// were any.
+func (c *Category) ExecuteAll(opts FanOutOptions, extra ...string) {
+	if len(extra) == 0 {
+		fmt.Println("Give the command to run on every host")
+		exit(exitError)
+	}
+	if len(c.Hosts) == 0 {
+		fmt.Println("There are no hosts to run on")
+		exit(exitNotFound)
+	}
+
+	ExecuteCommand(c.Hosts, remoteCommand(extra)[0], opts)
+}
+
+// SSHCommands returns the command line of every host in the category, with
+// all the options it inherits, aligned after the FQDNs. For most hosts that is
+// an ssh command, but hosts with a command of their own show that instead.
+func (c *Category) SSHCommands() string {
+	width := 0
+	for _, host := range c.Hosts {
+		if len(host.FQDN) > width {
+			width = len(host.FQDN)
+		}
+	}
+
+	var out bytes.Buffer
+	for _, host := range c.Hosts {
+		args, err := host.connectCommand()
+		if err != nil {
+			fmt.Fprintf(&out, "%-*s  %s\n", width, host.FQDN, err)
+			continue
+		}
+
+		for x, arg := range args {
+			args[x] = shellQuote(arg)
+		}
+		fmt.Fprintf(&out, "%-*s  %s\n", width, host.FQDN, strings.Join(args, " "))
+	}
+
+	return out.String()
+}
+
 // List returns a list of the types in the category map
 func (h HostType) List() (keys []string) {
 	for key := range h {
@@ -189,7 +704,8 @@ func (h HostType) List() (keys []string) {
 	return keys
 }
 
-// Hosts returns an array of all the Types in the category map
+// Hosts returns an array of all the hosts in the category map, with hosts that
+// are in several categories repeated. See UniqueHosts().
 func (h HostType) Hosts() (hosts []Host) {
 	for _, cat := range h {
 		for _, host := range cat.Hosts {
@@ -200,53 +716,338 @@ func (h HostType) Hosts() (hosts []Host) {
 	return hosts
 }
 
-// PrimaryHost returns the primary host of the category
+// UniqueHosts returns every host once, even if it is in several categories
+//
+// The categories are gone through by name, and the first definition of a host
+// is the one that is kept.
+func (h HostType) UniqueHosts() []Host {
+	return h.uniqueHosts(func(Host) bool { return true })
+}
+
+// uniqueHosts returns every host that keep is true for once, even if it is in
+// several categories
+//
+// The definitions of a host are checked one by one, so that a host is
+// returned if any of them match, not only if the first one does.
+func (h HostType) uniqueHosts(keep func(Host) bool) (hosts []Host) {
+	seen := make(map[string]bool)
+	for _, key := range h.List() {
+		for _, host := range h[key].Hosts {
+			if seen[host.FQDN] || !keep(host) {
+				continue
+			}
+
+			seen[host.FQDN] = true
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+// Tags returns a sorted list of all the tags used by the hosts
+func (h HostType) Tags() []string {
+	seen := make(map[string]bool)
+	for _, host := range h.Hosts() {
+		for _, tag := range host.Tags {
+			seen[tag] = true
+		}
+	}
+
+	tags := make([]string, 0, len(seen))
+	for tag := range seen {
+		tags = append(tags, tag)
+	}
+
+	sort.Strings(tags)
+	return tags
+}
+
+// HostsByTag returns the hosts with a tag, regardless of category. Hosts in
+// several categories are only returned once.
+//
+// It is an error if no host has the tag.
+func (h HostType) HostsByTag(tag string) (hosts []Host, err error) {
+	hosts = h.uniqueHosts(func(host Host) bool { return host.HasTag(tag) })
+
+	if len(hosts) == 0 {
+		err = fmt.Errorf(
+			"No hosts are tagged %s. Known tags are: %s",
+			tag,
+			strings.Join(h.Tags(), ", "),
+		)
+	}
+	return
+}
+
+// Envs returns a sorted list of all the environments of the hosts
+func (h HostType) Envs() []string {
+	seen := make(map[string]bool)
+	for _, host := range h.Hosts() {
+		if host.Env != "" {
+			seen[host.Env] = true
+		}
+	}
+
+	envs := make([]string, 0, len(seen))
+	for env := range seen {
+		envs = append(envs, env)
+	}
+
+	sort.Strings(envs)
+	return envs
+}
+
+// HostsByEnv returns the hosts in an environment, regardless of category
+//
+// It is an error if no host is in the environment. Hosts without an env are
+// warned about, since they might belong to it without saying so.
+func (h HostType) HostsByEnv(env string) (hosts []Host, err error) {
+	hosts = h.uniqueHosts(func(host Host) bool { return host.Env == env })
+
+	hasEnv := make(map[string]bool)
+	for _, host := range h.Hosts() {
+		hasEnv[host.FQDN] = hasEnv[host.FQDN] || host.Env != ""
+	}
+
+	var missing []string
+	for _, host := range h.UniqueHosts() {
+		if !hasEnv[host.FQDN] {
+			missing = append(missing, host.FQDN)
+		}
+	}
+
+	if len(missing) != 0 {
+		log.Printf("Warning: no env set for %s", strings.Join(missing, ", "))
+	}
+
+	if len(hosts) == 0 {
+		err = fmt.Errorf(
+			"No hosts are in the %s env. Known envs are: %s",
+			env,
+			strings.Join(h.Envs(), ", "),
+		)
+	}
+	return
+}
+
+// HostsBySelector returns the hosts picked by a selector, which is either
+// `env:<env>` or a tag
+func (h HostType) HostsBySelector(selector string) ([]Host, error) {
+	if strings.HasPrefix(selector, "env:") {
+		return h.HostsByEnv(strings.TrimPrefix(selector, "env:"))
+	}
+	return h.HostsByTag(selector)
+}
+
+// PrimaryHost returns the primary host of the primary category, or nil if no
+// category is primary. If several are, the first one by name is used.
 func (h HostType) PrimaryHost() *Host {
-	for _, cat := range h {
-		if cat.Primary {
+	for _, key := range h.List() {
+		if cat := h[key]; cat.Primary {
 			return cat.PrimaryHost()
 		}
 	}
 	return nil
 }
 
+// Primaries returns the primary host of every category, in the order of
+// List(), along with the categories that were skipped because they have no
+// hosts
+func (h HostType) Primaries() (hosts []Host, empty []string) {
+	for _, key := range h.List() {
+		cat := h[key]
+		if len(cat.Hosts) == 0 {
+			empty = append(empty, key)
+			continue
+		}
+		hosts = append(hosts, *cat.PrimaryHost())
+	}
+	return
+}
+
+// ExecutePrimaries runs a command on the primary host of every category, to
+// check one host of each tier. Without a command, they are all opened in tmux
+// panes instead. Categories without hosts are reported and skipped.
+func (h HostType) ExecutePrimaries(args []string, opts FanOutOptions) {
+	hosts, empty := h.Primaries()
+	for _, key := range empty {
+		fmt.Printf("Skipping %s, it has no hosts\n", key)
+	}
+	if len(hosts) == 0 {
+		fmt.Println("No hosts to run on")
+		exit(exitError)
+	}
+
+	if len(args) == 0 {
+		if err := OpenPanes(hosts); err != nil {
+			fmt.Println(err)
+			exit(exitError)
+		}
+		return
+	}
+
+	ExecuteCommand(hosts, remoteCommand(args)[0], opts)
+}
+
+// PrimaryFirst returns the types like List(), except that primary categories
+// come first
+func (h HostType) PrimaryFirst() []string {
+	keys := h.List()
+	sort.Stable(byPrimary{keys, h})
+	return keys
+}
+
+// byPrimary sorts primary categories before the others, keeping the order
+// within both
+type byPrimary struct {
+	keys []string
+	h    HostType
+}
+
+func (s byPrimary) Len() int      { return len(s.keys) }
+func (s byPrimary) Swap(i, j int) { s.keys[i], s.keys[j] = s.keys[j], s.keys[i] }
+func (s byPrimary) Less(i, j int) bool {
+	return s.h[s.keys[i]].Primary && !s.h[s.keys[j]].Primary
+}
+
+// PrintOptions changes what PrintType prints
+type PrintOptions struct {
+	// PrimaryFirst puts the primary category first, instead of sorting the
+	// categories by name only
+	PrimaryFirst bool
+
+	// SummaryOnly leaves the hosts out, for an overview of the categories
+	SummaryOnly bool
+
+	// ShowKind shows the kind of every host. Otherwise it is only shown for
+	// hosts whose kind is not the default kind of their category.
+	ShowKind bool
+
+	// Cols is the width of the screen. Host summaries are truncated to fit in
+	// it, unless it is zero.
+	Cols int
+}
+
 // PrintType prints a pretty list of the different types and their hosts
-func (h HostType) PrintType() {
+func (h HostType) PrintType(opts PrintOptions) {
+	keys := h.List()
+	if opts.PrimaryFirst {
+		keys = h.PrimaryFirst()
+	}
+
+	if plain {
+		page(h.plainTypes(keys, opts))
+		return
+	}
+	page(h.prettyTypes(keys, opts))
+}
+
+// showKind returns true if the kind of the host should be shown in listings
+func (c Category) showKind(host Host, always bool) bool {
+	if host.Kind == "" {
+		return false
+	}
+	return always || (c.Kind != "" && host.Kind != c.Kind)
+}
+
+// prettyTypes formats the types and their hosts with colors and indexes
+func (h HostType) prettyTypes(keys []string, opts PrintOptions) string {
 	blue := color.New(color.FgBlue, color.Bold).SprintfFunc()
 	green := color.New(color.FgGreen, color.Bold).SprintfFunc()
 	cyan := color.New(color.FgCyan, color.Bold).SprintfFunc()
 	yellow := color.New(color.FgYellow).SprintfFunc()
 	hiyellow := color.New(color.FgHiYellow, color.Bold).SprintfFunc()
 	grey := color.New(color.FgWhite).SprintfFunc()
+	magenta := color.New(color.FgMagenta).SprintfFunc()
 
-	for _, t := range h.List() {
-		fmt.Println(fmt.Sprintf("%s:", cyan(t)))
+	var out bytes.Buffer
+	for _, t := range keys {
+		fmt.Fprintf(&out, "%s:\n", cyan(t))
 		cat := h[t]
-		fmt.Printf("  %s\n", text.Wrap(cat.Summary, 80))
+		fmt.Fprintf(&out, "  %s\n", text.Wrap(cat.Summary, 80))
+		if opts.SummaryOnly {
+			continue
+		}
 		for x, host := range cat.Hosts {
-			// Print the main host item
-			fmt.Printf(
+			// Print the main host item, counting the columns it takes up
+			// without the colors
+			fmt.Fprintf(
+				&out,
 				"  %s%s%s %s",
 				yellow("["),
 				hiyellow(strconv.Itoa(x)),
 				yellow("]"),
 				blue(host.FQDN),
 			)
+			used := 5 + len(strconv.Itoa(x)) + displayWidth(host.FQDN)
+
+			if cat.showKind(host, opts.ShowKind) {
+				fmt.Fprintf(&out, " [%s]", magenta(host.Kind))
+				used += 3 + displayWidth(host.Kind)
+			}
 
 			// If the host is primary, mark that clearly
 			if host.Primary {
-				fmt.Printf(" (%s)", green("primary"))
+				fmt.Fprintf(&out, " (%s)", green("primary"))
+				used += 10
+			}
+
+			// If the host has a summary, add that as well, if it fits at all
+			summary := host.Summary
+			if opts.Cols != 0 {
+				summary = truncate(summary, opts.Cols-used-3)
 			}
+			if summary != "" {
+				fmt.Fprintf(&out, " (%s)", grey(summary))
+			}
+
+			fmt.Fprintln(&out)
+		}
+		fmt.Fprintln(&out)
+	}
+
+	return out.String()
+}
+
+// plainTypes formats the hosts as one line each, with the category, the FQDN,
+// "primary" if the host is the primary and the summary separated by tabs. With
+// ShowKind, the kind is added at the end.
+//
+// With SummaryOnly, there is one line for each category instead, with its
+// name and summary.
+func (h HostType) plainTypes(keys []string, opts PrintOptions) string {
+	var out bytes.Buffer
+	for _, t := range keys {
+		if opts.SummaryOnly {
+			fmt.Fprintf(&out, "%s\t%s\n", t, h[t].Summary)
+			continue
+		}
 
-			// If the host has a summary, add that as well
-			if host.Summary != "" {
-				fmt.Printf(" (%s)", grey(host.Summary))
+		for _, host := range h[t].Hosts {
+			primary := ""
+			if host.Primary {
+				primary = "primary"
+			}
+			fmt.Fprintf(&out, "%s\t%s\t%s\t%s", t, host.FQDN, primary, host.Summary)
+			if opts.ShowKind {
+				fmt.Fprintf(&out, "\t%s", host.Kind)
 			}
+			fmt.Fprintln(&out)
+		}
+	}
 
-			fmt.Println()
+	return out.String()
+}
+
+// HasTag returns true if the host has the tag
+func (h *Host) HasTag(tag string) bool {
+	for _, t := range h.Tags {
+		if t == tag {
+			return true
 		}
-		fmt.Println()
 	}
+	return false
 }
 
 // hasHost returns true if there is a Host definition and false if not.
@@ -254,24 +1055,192 @@ func (h *Host) hasHost() bool {
 	return h.FQDN != ""
 }
 
+// sshVerbosity is the number of -v flags given to ssh, for debugging
+// connections
+var sshVerbosity int
+
+// command returns the full ssh command line used to connect to the host
+func (h *Host) command(extra ...string) []string {
+	dest := h.FQDN
+	if h.User != "" {
+		dest = h.User + "@" + dest
+	}
+
+	args := []string{"ssh", dest, "-A"}
+
+	// Interactive shells need a pty, but it mangles the output of one-shot
+	// commands. Those only get one if the host asks for it.
+	if len(extra) == 0 || h.TTY {
+		args = append(args, "-t")
+	}
+
+	if h.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(h.Port))
+	}
+	if h.IdentityFile != "" {
+		args = append(args, "-i", h.IdentityFile)
+	}
+	if len(h.Jumps) != 0 {
+		args = append(args, "-J", strings.Join(h.Jumps, ","))
+	} else if h.Jump != "" {
+		args = append(args, "-J", h.Jump)
+	}
+	for _, opt := range h.Options {
+		args = append(args, "-o", opt)
+	}
+	for _, opt := range h.keepAliveOptions() {
+		args = append(args, "-o", opt)
+	}
+	for _, name := range h.secretNames() {
+		args = append(args, "-o", "SendEnv="+name)
+	}
+	for x := 0; x < sshVerbosity; x++ {
+		args = append(args, "-v")
+	}
+
+	return append(args, remoteCommand(extra)...)
+}
+
+// env resolves the secrets of the host into the variables that the command
+// that reaches it gets
+func (h *Host) env() ([]string, error) {
+	secrets, err := resolveSecrets(h.Secrets)
+	if err != nil {
+		return nil, err
+	}
+	return secretEnv(secrets)
+}
+
+// secretNames returns the names of the secrets of the host, sorted
+func (h *Host) secretNames() []string {
+	names := make([]string, 0, len(h.Secrets))
+	for name := range h.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// connectCommand returns the command line that reaches the host
+//
+// That is ssh, unless the host has a command template of its own. Any extra
+// arguments are put where that template has `{{.Command}}`, quoted the same
+// way that they would have been sent over ssh. A template without it cannot
+// run anything but itself.
+func (h *Host) connectCommand(extra ...string) ([]string, error) {
+	if h.Command == "" {
+		return h.command(extra...), nil
+	}
+
+	t, err := template.New("command").Parse(h.Command)
+	if err != nil {
+		return nil, fmt.Errorf("Bad command template: %s", err)
+	}
+
+	data := &commandTemplate{Host: *h}
+	if rc := remoteCommand(extra); len(rc) != 0 {
+		data.command = rc[0]
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("Bad command template for %s: %s", h.FQDN, err)
+	}
+	if data.command != "" && !data.used {
+		return nil, fmt.Errorf("The command of %s has no {{.Command}} to run %q with", h.FQDN, data.command)
+	}
+
+	return []string{"sh", "-c", buf.String()}, nil
+}
+
+// commandTemplate is what the command template of a host is filled in with:
+// the fields of the host, and the command to run on it as .Command
+type commandTemplate struct {
+	Host
+	command string
+	used    bool
+}
+
+// Command returns the command to run on the host, and notes that the template
+// has somewhere to put it
+func (c *commandTemplate) Command() string {
+	c.used = true
+	return c.command
+}
+
+// remoteCommand turns arguments into what ssh should send to the host
+//
+// ssh joins its arguments with spaces and lets the remote shell split them
+// again, which mangles any argument with spaces or special characters in it. A
+// single argument is taken to be a full shell command line and is sent as is,
+// but several arguments are quoted one by one so that each arrives intact.
+func remoteCommand(extra []string) []string {
+	if len(extra) <= 1 {
+		return extra
+	}
+
+	quoted := make([]string, len(extra))
+	for x, arg := range extra {
+		quoted[x] = shellQuote(arg)
+	}
+
+	return []string{strings.Join(quoted, " ")}
+}
+
+// connectionLog is where Execute says which host it is connecting to, so that
+// it is clear what a query or an index picked. It is stderr unless --quiet is
+// given, to stay out of the way of piped output.
+var connectionLog io.Writer = os.Stderr
+
+// selection returns which host of which category this is, like
+// "web[2] = web3.company.net", or just the FQDN for hosts that are not from a
+// host file
+func (h *Host) selection() string {
+	if h.category == "" {
+		return h.FQDN
+	}
+	return fmt.Sprintf("%s[%d] = %s", h.category, h.index, h.FQDN)
+}
+
 // Execute runs a command on the server
 // The default is to open a shell. If arguments are given, those arguments
-// will be executed verbatim on the host.
-func (h *Host) Execute(extra ...string) {
-	ssh, _ := exec.LookPath("ssh")
+// will be executed verbatim on the host, without a pty unless h.TTY is set.
+// Hosts with a command of their own run that instead of ssh. The secrets of
+// the host are resolved first and put in the environment of that command.
+//
+// It returns errInterrupted if the session was interrupted, and any other
+// failure as it is. The callers decide what to exit with.
+func (h *Host) Execute(extra ...string) error {
+	args, err := h.connectCommand(extra...)
+	if err != nil {
+		return err
+	}
 
-	args := append([]string{ssh, h.FQDN, "-A", "-t"}, extra...)
+	env, err := h.env()
+	if err != nil {
+		return err
+	}
 
-	cmd := exec.Cmd{
-		Path:   ssh,
-		Args:   args,
-		Stdout: os.Stdout,
-		Stderr: os.Stderr,
-		Stdin:  os.Stdin,
+	if transcriptDir != "" {
+		fn, err := startTranscript(transcriptDir, h.FQDN, time.Now())
+		if err != nil {
+			return fmt.Errorf("Could not start the transcript: %s", err)
+		}
+		fmt.Fprintln(os.Stderr, "Recording the session to", fn)
+		args = transcriptArgs(runtime.GOOS, args, fn)
 	}
 
-	err := cmd.Run()
+	// An interrupt is passed on to ssh rather than killing sagacity under it
+	ctx, stop := interruptContext()
+	defer stop()
+
+	fmt.Fprintln(connectionLog, "connecting to", h.selection())
+	err = sshRunner.Run(ctx, args, env, os.Stdin, os.Stdout, os.Stderr)
+	if err != nil && ctx.Err() != nil {
+		return errInterrupted
+	}
 	if err != nil {
-		log.Fatal("ssh command failed: ", err)
+		return fmt.Errorf("%s command failed: %s", args[0], err)
 	}
+	return nil
 }
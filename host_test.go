@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"github.com/fatih/color"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"log"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -35,6 +39,66 @@ func TestHostInfoHostsAreSet(t *testing.T) {
 	assert.Equal(2, len(h.Types["task"].Hosts))
 }
 
+func TestCategorySelectAllHosts(t *testing.T) {
+	assert := assert.New(t)
+	cat := testHostInfo().Types["ro"]
+
+	hosts, err := cat.Select([]string{})
+
+	assert.Nil(err)
+	assert.Equal(4, len(hosts))
+}
+
+func TestCategorySelectByIndexAndFQDN(t *testing.T) {
+	assert := assert.New(t)
+	cat := testHostInfo().Types["ro"]
+
+	hosts, err := cat.Select([]string{"1", "db4.cluster3.company.net"})
+
+	assert.Nil(err)
+	assert.Equal("db5.cluster3.company.net", hosts[0].FQDN)
+	assert.Equal("db4.cluster3.company.net", hosts[1].FQDN)
+}
+
+func TestCategoryMatch(t *testing.T) {
+	assert := assert.New(t)
+	cat := testHostInfo().Types["ro"]
+
+	assert.Equal(4, len(cat.Match("cluster3")))
+	assert.Equal("db4.cluster3.company.net", cat.Match("long queries")[0].FQDN)
+	assert.Equal(0, len(cat.Match("cluster6")))
+}
+
+func TestPickHostWithoutTTY(t *testing.T) {
+	assert := assert.New(t)
+	cat := testHostInfo().Types["ro"]
+
+	host, err := pickHost("db5", cat.Match("db5"), false)
+	assert.Nil(err)
+	assert.Equal("db5.cluster3.company.net", host.FQDN)
+
+	_, err = pickHost("db", cat.Match("db"), false)
+	assert.Equal(
+		"db is ambiguous. Choices are: db2.cluster3.company.net, db5.cluster3.company.net, "+
+			"db6.cluster3.company.net, db4.cluster3.company.net",
+		err.Error(),
+	)
+
+	_, err = pickHost("web", cat.Match("web"), false)
+	assert.Equal("No host matches web", err.Error())
+}
+
+func TestCategorySelectUnknownHost(t *testing.T) {
+	assert := assert.New(t)
+	cat := testHostInfo().Types["ro"]
+
+	_, err := cat.Select([]string{"4"})
+	assert.NotNil(err)
+
+	_, err = cat.Select([]string{"db9.cluster3.company.net"})
+	assert.NotNil(err)
+}
+
 func ExampleHostType() {
 	data, err := ioutil.ReadFile("test/host_example_config.yaml")
 	if err != nil {
@@ -46,7 +110,7 @@ func ExampleHostType() {
 	repos := LoadRepos(conf)
 
 	app := BuildCLI(repos, conf)
-	app.Run([]string{"sagacity", "printout", "hosts", "db"})
+	app.Run([]string{"sagacity", "printout", "hosts", "db", "--list"})
 
 	// Output: [36;1mmaster[0m:
 	//   Master database, read/write
@@ -73,3 +137,557 @@ func ExampleHostType() {
 	//   WAL archive storage machines
 	//   [33m[[0m[93;1m0[0m[33m][0m [34;1mdb7.cluster3.company.net[0m
 }
+
+func TestHostTypePrimaryFirst(t *testing.T) {
+	assert := assert.New(t)
+	h := testHostInfo()
+
+	assert.Equal([]string{"master", "ro", "standby", "task", "wal"}, h.Types.List())
+
+	h.Types["standby"] = Category{Primary: true}
+	delete(h.Types, "master")
+	assert.Equal([]string{"standby", "ro", "task", "wal"}, h.Types.PrimaryFirst())
+}
+
+func TestHostTypePrimaryFirstWithoutPrimary(t *testing.T) {
+	assert := assert.New(t)
+	h := testHostInfo()
+	delete(h.Types, "master")
+
+	assert.Equal(h.Types.List(), h.Types.PrimaryFirst())
+}
+
+func TestHostTypePrimaryHost(t *testing.T) {
+	assert := assert.New(t)
+	h := testHostInfo()
+
+	assert.Equal("db1.cluster6.company.net", h.Types.PrimaryHost().FQDN)
+}
+
+func TestCategoryLookup(t *testing.T) {
+	assert := assert.New(t)
+	cat := NewRepo("test/kinds/").Items["hosts"].(*HostInfo).Types["web"]
+
+	host, err := cat.Lookup("1")
+	assert.Nil(err)
+	assert.Equal("web2.company.net", host.FQDN)
+
+	host, err = cat.Lookup("web1.company.net")
+	assert.Nil(err)
+	assert.Equal("web1.company.net", host.FQDN)
+
+	_, err = cat.Lookup("5")
+	assert.EqualError(err, "No host with index 5. Choices are 0 to 1")
+	_, err = cat.Lookup("-1")
+	assert.EqualError(err, "No host with index -1. Choices are 0 to 1")
+
+	_, err = cat.Lookup("web3.company.net")
+	assert.EqualError(err, "No such host: web3.company.net. Choices are 0 to 1 or web1.company.net, web2.company.net")
+}
+
+func TestEmptyCategory(t *testing.T) {
+	assert := assert.New(t)
+	cat := Category{}
+
+	assert.Nil(cat.PrimaryHost())
+
+	_, err := cat.Lookup("0")
+	assert.NotNil(err)
+
+	assert.Equal(exitNotFound, exitCode(func() { cat.executePrimary("web") }))
+}
+
+func TestHostTypePrimaryHostWithoutPrimaryCategory(t *testing.T) {
+	assert := assert.New(t)
+	h := testHostInfo()
+	delete(h.Types, "master")
+
+	assert.Nil(h.Types.PrimaryHost())
+}
+
+func testSSHDefaults() *HostInfo {
+	r := NewRepo("test/sshdefaults/")
+	return r.Items["hosts"].(*HostInfo)
+}
+
+func TestHostSSHOptionsPrecedence(t *testing.T) {
+	assert := assert.New(t)
+	h := testSSHDefaults()
+
+	// Nothing on the host; the jump is from the category, the port from the
+	// file and the user and options from the repo.
+	web1 := h.Types["web"].Hosts[0]
+	assert.Equal("repouser", web1.User)
+	assert.Equal(2000, web1.Port)
+	assert.Equal("catjump.company.net", web1.Jump)
+	assert.Equal([]string{"StrictHostKeyChecking=no"}, web1.Options)
+
+	// The host sets the user itself
+	web2 := h.Types["web"].Hosts[1]
+	assert.Equal("hostuser", web2.User)
+	assert.Equal(2000, web2.Port)
+
+	// No category options; the host has a port and the jump comes from the
+	// file defaults
+	db1 := h.Types["db"].Hosts[0]
+	assert.Equal(3000, db1.Port)
+	assert.Equal("filejump.company.net", db1.Jump)
+	assert.Equal("repouser", db1.User)
+}
+
+func TestHostCommandUsesSSHOptions(t *testing.T) {
+	assert := assert.New(t)
+	h := testSSHDefaults()
+	web1 := h.Types["web"].Hosts[0]
+
+	assert.Equal([]string{
+		"ssh", "repouser@web1.company.net", "-A", "-t",
+		"-p", "2000",
+		"-J", "catjump.company.net",
+		"-o", "StrictHostKeyChecking=no",
+	}, web1.command())
+}
+
+func TestHostCommandWithoutOptions(t *testing.T) {
+	assert := assert.New(t)
+	host := Host{FQDN: "db1.company.net"}
+
+	assert.Equal([]string{"ssh", "db1.company.net", "-A", "-t"}, host.command())
+}
+
+func TestHostCommandOnlyAllocatesTTYForShells(t *testing.T) {
+	assert := assert.New(t)
+	host := Host{FQDN: "db1.company.net"}
+
+	// Interactive shell
+	assert.Equal([]string{"ssh", "db1.company.net", "-A", "-t"}, host.command())
+
+	// One-shot command
+	assert.Equal([]string{"ssh", "db1.company.net", "-A", "uptime"}, host.command("uptime"))
+
+	// One-shot command that asks for a pty
+	host.TTY = true
+	assert.Equal([]string{"ssh", "db1.company.net", "-A", "-t", "uptime"}, host.command("uptime"))
+}
+
+func TestHostCommandVerbosity(t *testing.T) {
+	assert := assert.New(t)
+	defer func(v int) { sshVerbosity = v }(sshVerbosity)
+	sshVerbosity = 3
+
+	host := Host{FQDN: "db1.company.net", SSHOptions: SSHOptions{Port: 2222}}
+
+	assert.Equal([]string{
+		"ssh", "db1.company.net", "-A", "-p", "2222", "-v", "-v", "-v", "uptime",
+	}, host.command("uptime"))
+}
+
+func TestHostCommandKeepAlive(t *testing.T) {
+	assert := assert.New(t)
+
+	// Set on the category, with the count from further up
+	opts := SSHOptions{KeepAlive: 30}.merge(SSHOptions{User: "deploy", KeepAliveCount: 5})
+	host := Host{FQDN: "db1.company.net", SSHOptions: SSHOptions{Options: []string{"Compression=yes"}}.merge(opts)}
+
+	assert.Equal([]string{
+		"ssh", "deploy@db1.company.net", "-A", "-t",
+		"-o", "Compression=yes", "-o", "ServerAliveInterval=30", "-o", "ServerAliveCountMax=5",
+	}, host.command())
+
+	// Nothing is added unless it is set somewhere
+	host = Host{FQDN: "db1.company.net"}
+	assert.Equal([]string{"ssh", "db1.company.net", "-A", "-t"}, host.command())
+}
+
+func TestHostCommandUserPortAndIdentity(t *testing.T) {
+	assert := assert.New(t)
+	defer useFS(memFS{
+		"/mem/hosts.yaml": `type: host
+defaults:
+  user: deploy
+  identity_file: ~/.ssh/deploy
+types:
+  db:
+    port: 2222
+    hosts:
+      - fqdn: db1.company.net
+      - fqdn: db2.company.net
+        user: root
+        identity_file: ~/.ssh/root
+`,
+	})()
+
+	item, err := LoadItem(&Repo{}, "/mem/hosts.yaml")
+	assert.Nil(err)
+	hosts := item.(*HostInfo).Types["db"].Hosts
+
+	assert.Equal([]string{
+		"ssh", "deploy@db1.company.net", "-A", "-t", "-p", "2222", "-i", "~/.ssh/deploy",
+	}, hosts[0].command())
+	assert.Equal([]string{
+		"ssh", "root@db2.company.net", "-A", "-t", "-p", "2222", "-i", "~/.ssh/root",
+	}, hosts[1].command())
+
+	// Without any of them, the command stays the same as ever
+	host := Host{FQDN: "db3.company.net"}
+	assert.Equal([]string{"ssh", "db3.company.net", "-A", "-t"}, host.command())
+}
+
+func TestTakeVerbosity(t *testing.T) {
+	assert := assert.New(t)
+
+	level, rest := takeVerbosity([]string{"sagacity", "-vv", "--quiet", "-v", "infra", "hosts"})
+
+	assert.Equal(3, level)
+	assert.Equal([]string{"sagacity", "--quiet", "infra", "hosts"}, rest)
+}
+
+func TestTakeVerbosityLeavesTheCommandAlone(t *testing.T) {
+	assert := assert.New(t)
+
+	level, rest := takeVerbosity([]string{"sagacity", "-v", "infra", "hosts", "web", "grep", "-v", "err"})
+
+	assert.Equal(1, level)
+	assert.Equal([]string{"sagacity", "infra", "hosts", "web", "grep", "-v", "err"}, rest)
+}
+
+func TestGlobalArgs(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(
+		[]string{"--plain", "--root", "docs", "--git-timeout=1m"},
+		globalArgs([]string{"sagacity", "--plain", "--root", "docs", "--git-timeout=1m", "infra", "--no-pager"}),
+	)
+	assert.Equal(
+		[]string{"--quiet"},
+		globalArgs([]string{"sagacity", "--quiet", "exec", "db", "--", "pg_dump", "--quiet"}),
+	)
+	assert.Equal([]string{}, globalArgs([]string{"sagacity", "--", "--show-secrets"}))
+	assert.Equal([]string{"--full"}, globalArgs([]string{"sagacity", "--full"}))
+}
+
+func TestShellQuote(t *testing.T) {
+	assert := assert.New(t)
+
+	for in, out := range map[string]string{
+		"uptime":             "uptime",
+		"/var/log/syslog":    "/var/log/syslog",
+		"hello world":        "'hello world'",
+		"it's":               `'it'"'"'s'`,
+		"$HOME; rm -rf /":    "'$HOME; rm -rf /'",
+		"`id` && echo \"x\"": "'`id` && echo \"x\"'",
+		"":                   "''",
+	} {
+		assert.Equal(out, shellQuote(in), in)
+	}
+}
+
+func TestHostCommandQuotesRemoteArguments(t *testing.T) {
+	assert := assert.New(t)
+	host := Host{FQDN: "db1.company.net"}
+
+	// A single argument is a full command line and is sent verbatim
+	assert.Equal(
+		[]string{"ssh", "db1.company.net", "-A", "echo hello world | wc -w"},
+		host.command("echo hello world | wc -w"),
+	)
+
+	// Several arguments arrive as separate words
+	assert.Equal(
+		[]string{"ssh", "db1.company.net", "-A", `grep 'hello world' 'it'"'"'s' /tmp/x`},
+		host.command("grep", "hello world", "it's", "/tmp/x"),
+	)
+}
+
+func testTags() *HostInfo {
+	r := NewRepo("test/tags/")
+	return r.Items["fleet"].(*HostInfo)
+}
+
+func TestHostsByTagSpansCategories(t *testing.T) {
+	assert := assert.New(t)
+	h := testTags()
+
+	hosts, err := h.Types.HostsByTag("prod")
+
+	assert.Nil(err)
+	assert.Equal(2, len(hosts))
+	assert.Equal("db1.company.net", hosts[0].FQDN)
+	assert.Equal("web1.company.net", hosts[1].FQDN)
+}
+
+func TestHostsByTagWithMultiTagHosts(t *testing.T) {
+	assert := assert.New(t)
+	h := testTags()
+
+	frontend, _ := h.Types.HostsByTag("frontend")
+	staging, _ := h.Types.HostsByTag("staging")
+
+	assert.Equal(2, len(frontend))
+	assert.Equal(1, len(staging))
+	assert.Equal("web2.company.net", staging[0].FQDN)
+}
+
+func TestHostsByTagUnknownTag(t *testing.T) {
+	assert := assert.New(t)
+	h := testTags()
+
+	_, err := h.Types.HostsByTag("dev")
+
+	assert.NotNil(err)
+	assert.True(strings.Contains(err.Error(), "frontend, prod, staging"))
+}
+
+func TestHostsByEnvWarnsAboutHostsWithoutEnv(t *testing.T) {
+	assert := assert.New(t)
+	h := NewRepo("test/tags/").Items["envs"].(*HostInfo)
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	hosts, err := h.Types.HostsBySelector("env:prod")
+
+	assert.Nil(err)
+	assert.Equal(2, len(hosts))
+	assert.Equal("db1.company.net", hosts[0].FQDN)
+	assert.Equal("web1.company.net", hosts[1].FQDN)
+	assert.Contains(logged.String(), "no env set for db2.company.net")
+}
+
+func TestHostsByEnvUnknownEnv(t *testing.T) {
+	assert := assert.New(t)
+	h := NewRepo("test/tags/").Items["envs"].(*HostInfo)
+
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	_, err := h.Types.HostsByEnv("dev")
+
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "prod, staging")
+}
+
+func TestCategorySSHCommands(t *testing.T) {
+	assert := assert.New(t)
+	cat := Category{Hosts: []Host{
+		{FQDN: "web1.company.net", SSHOptions: SSHOptions{User: "deploy", Port: 2222}},
+		{FQDN: "web10.company.net", SSHOptions: SSHOptions{Jump: "bastion", Options: []string{"ForwardX11=no"}}},
+	}}
+
+	assert.Equal(
+		"web1.company.net   ssh deploy@web1.company.net -A -t -p 2222\n"+
+			"web10.company.net  ssh web10.company.net -A -t -J bastion -o ForwardX11=no\n",
+		cat.SSHCommands(),
+	)
+}
+
+func TestCategoryFirstAndLast(t *testing.T) {
+	assert := assert.New(t)
+	cat := testHostInfo().Types["ro"]
+
+	first, err := cat.First()
+	assert.Nil(err)
+	assert.Equal("db2.cluster3.company.net", first.FQDN)
+
+	last, err := cat.Last()
+	assert.Nil(err)
+	assert.Equal("db4.cluster3.company.net", last.FQDN)
+}
+
+func TestCategoryFirstAndLastWithoutHosts(t *testing.T) {
+	assert := assert.New(t)
+	cat := Category{}
+
+	_, err := cat.First()
+	assert.EqualError(err, "No hosts in the category")
+
+	_, err = cat.Last()
+	assert.EqualError(err, "No hosts in the category")
+}
+
+func TestHostTypeUniqueHosts(t *testing.T) {
+	assert := assert.New(t)
+	h := NewRepo("test/shared/").Items["hosts"].(*HostInfo)
+
+	assert.Equal(4, len(h.Types.Hosts()))
+
+	var fqdns []string
+	for _, host := range h.Types.UniqueHosts() {
+		fqdns = append(fqdns, host.FQDN)
+	}
+	assert.Equal([]string{"web1.company.net", "both.company.net", "worker1.company.net"}, fqdns)
+}
+
+func TestHostsByTagReturnsSharedHostOnce(t *testing.T) {
+	assert := assert.New(t)
+	h := NewRepo("test/shared/").Items["hosts"].(*HostInfo)
+
+	hosts, err := h.Types.HostsByTag("prod")
+
+	assert.Nil(err)
+	assert.Equal(2, len(hosts))
+}
+
+func TestHostsBySelectorChecksEveryDefinition(t *testing.T) {
+	assert := assert.New(t)
+	h := NewRepo("test/shared/").Items["hosts"].(*HostInfo)
+
+	// both.company.net is only tagged batch and in staging in the worker
+	// category, which comes after web
+	hosts, err := h.Types.HostsByTag("batch")
+	assert.Nil(err)
+	assert.Equal(1, len(hosts))
+	assert.Equal("both.company.net", hosts[0].FQDN)
+
+	hosts, err = h.Types.HostsByEnv("staging")
+	assert.Nil(err)
+	assert.Equal(1, len(hosts))
+	assert.Equal("both.company.net", hosts[0].FQDN)
+}
+
+func TestHostWithCommandRunsItLocally(t *testing.T) {
+	assert := assert.New(t)
+	f := &fakeRunner{}
+	defer useRunner(f)()
+
+	cat := NewRepo("test/hostcommand/").Items["pods"].(*HostInfo).Types["api"]
+	cat.Hosts[0].Execute()
+	cat.Hosts[0].Execute("ls", "/tmp dir")
+	cat.Hosts[1].Execute()
+
+	assert.Equal([][]string{
+		{"sh", "-c", "kubectl exec -it api-0 -- bash"},
+		{"sh", "-c", "kubectl exec -it api-0 -- ls '/tmp dir'"},
+		{"ssh", "api.company.net", "-A", "-t"},
+	}, f.calls)
+}
+
+func TestHostCommandWithoutPlaceholderRunsNothingElse(t *testing.T) {
+	assert := assert.New(t)
+	f := &fakeRunner{}
+	defer useRunner(f)()
+	host := &Host{FQDN: "api-0", Command: "kubectl exec -it {{.FQDN}} -- bash"}
+
+	err := host.Execute("ls")
+
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "has no {{.Command}}")
+	assert.Equal(0, len(f.calls))
+
+	assert.Nil(host.Execute())
+	assert.Equal([][]string{{"sh", "-c", "kubectl exec -it api-0 -- bash"}}, f.calls)
+}
+
+func TestHostCommandIsValidatedOnLoad(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := LoadItem(&Repo{}, "test/hostcommand_bad.yaml")
+
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "Bad command template for api-0")
+}
+
+func TestHostJumpChain(t *testing.T) {
+	assert := assert.New(t)
+	h := NewRepo("test/jumps/").Items["hosts"].(*HostInfo)
+	db := h.Types["db"]
+
+	// The bastion category is resolved to its primary host
+	assert.Equal(
+		[]string{"ssh", "db1.company.net", "-A", "-t", "-J", "outer.company.net,jump@bastion1.company.net:2222"},
+		db.Hosts[0].command(),
+	)
+
+	// The host replaces the jumps of the category
+	assert.Equal(
+		[]string{"ssh", "db2.company.net", "-A", "-t", "-J", "outer.company.net"},
+		db.Hosts[1].command(),
+	)
+}
+
+func TestPrettyTypesSummaryOnly(t *testing.T) {
+	assert := assert.New(t)
+	h := testHostInfo()
+
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	out := h.Types.prettyTypes([]string{"master", "ro"}, PrintOptions{SummaryOnly: true})
+
+	assert.Equal("master:\n  Master database, read/write\nro:\n  Read-only slaves\n", out)
+}
+
+func TestCategoryKindIsInherited(t *testing.T) {
+	assert := assert.New(t)
+	h := NewRepo("test/kinds/").Items["hosts"].(*HostInfo)
+
+	assert.Equal("frontend", h.Types["web"].Hosts[0].Kind)
+	assert.Equal("canary", h.Types["web"].Hosts[1].Kind)
+	assert.Equal("postgres", h.Types["db"].Hosts[0].Kind)
+}
+
+func TestPrettyTypesShowsKinds(t *testing.T) {
+	assert := assert.New(t)
+	h := NewRepo("test/kinds/").Items["hosts"].(*HostInfo)
+
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	// Only the kinds that differ from the default of the category
+	out := h.Types.prettyTypes([]string{"web", "db"}, PrintOptions{})
+	assert.Equal(
+		"web:\n  Frontends\n  [0] web1.company.net\n  [1] web2.company.net [canary]\n\n"+
+			"db:\n  Databases\n  [0] db1.company.net\n\n",
+		out,
+	)
+
+	out = h.Types.prettyTypes([]string{"web", "db"}, PrintOptions{ShowKind: true})
+	assert.Contains(out, "[0] web1.company.net [frontend]\n")
+	assert.Contains(out, "[0] db1.company.net [postgres]\n")
+}
+
+func TestPrettyTypesTruncatesSummaries(t *testing.T) {
+	assert := assert.New(t)
+	h := HostType{"db": Category{Hosts: []Host{
+		{FQDN: "db1", Summary: "データベースのサーバー"},
+		{FQDN: "db2", Summary: "short"},
+	}}}
+
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	// Twenty columns, including the parentheses
+	out := h.prettyTypes([]string{"db"}, PrintOptions{Cols: 20})
+	assert.Contains(out, "  [0] db1 (データ…)\n")
+	assert.Contains(out, "  [1] db2 (short)\n")
+
+	out = h.prettyTypes([]string{"db"}, PrintOptions{})
+	assert.Contains(out, "  [0] db1 (データベースのサーバー)\n")
+}
+
+func TestPrimaries(t *testing.T) {
+	assert := assert.New(t)
+	h := HostType{
+		"web": Category{Hosts: []Host{
+			{FQDN: "web1.company.net"},
+			{FQDN: "web2.company.net", Primary: true},
+		}},
+		"db": Category{Hosts: []Host{
+			{FQDN: "db1.company.net"},
+			{FQDN: "db2.company.net"},
+		}},
+		"queue": Category{},
+	}
+
+	hosts, empty := h.Primaries()
+
+	// Categories without a primary host fall back on their first one
+	assert.Equal(2, len(hosts))
+	assert.Equal("db1.company.net", hosts[0].FQDN)
+	assert.Equal("web2.company.net", hosts[1].FQDN)
+	assert.Equal([]string{"queue"}, empty)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parseCSVInventory reads an inventory with one host per line, as
+// `group,fqdn[,summary]`. A header line starting with `group` is skipped.
+func parseCSVInventory(r io.Reader) (HostType, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	types := HostType{}
+	for x, rec := range records {
+		if x == 0 && rec[0] == "group" {
+			continue
+		}
+		if len(rec) < 2 || rec[1] == "" {
+			return nil, fmt.Errorf("Line %d: expected group,fqdn[,summary]", x+1)
+		}
+
+		host := Host{FQDN: strings.TrimSpace(rec[1])}
+		if len(rec) > 2 {
+			host.Summary = strings.TrimSpace(rec[2])
+		}
+
+		group := strings.TrimSpace(rec[0])
+		cat := types[group]
+		cat.Hosts = append(cat.Hosts, host)
+		types[group] = cat
+	}
+
+	return types, nil
+}
+
+// parseINIInventory reads an Ansible-style ini inventory
+//
+// Every [group] becomes a category. The ansible_host, ansible_user and
+// ansible_port variables of a host are used for its FQDN, user and port, and
+// anything else is ignored, as are the :vars and :children sections. Hosts
+// that come before the first group end up in "ungrouped", like in Ansible.
+func parseINIInventory(r io.Reader) (HostType, error) {
+	types := HostType{}
+	group := "ungrouped"
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			group = strings.Trim(line, "[]")
+			if strings.Contains(group, ":") {
+				group = ""
+			}
+			continue
+		}
+		if group == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		host := Host{FQDN: fields[0]}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+
+			switch kv[0] {
+			case "ansible_host":
+				host.FQDN = kv[1]
+			case "ansible_user":
+				host.User = kv[1]
+			case "ansible_port":
+				port, err := strconv.Atoi(kv[1])
+				if err != nil {
+					return nil, fmt.Errorf("Line %d: bad ansible_port %q", n, kv[1])
+				}
+				host.Port = port
+			}
+		}
+
+		cat := types[group]
+		cat.Hosts = append(cat.Hosts, host)
+		types[group] = cat
+	}
+
+	return types, scanner.Err()
+}
+
+// ImportHosts turns an inventory into a host file
+//
+// Files ending in .csv are read as CSV, anything else as an Ansible ini
+// inventory. The host file is written to out, or printed if out is empty, and
+// what was imported is reported on stderr.
+func ImportHosts(fn, out string) {
+	f, err := os.Open(fn)
+	if err != nil {
+		fmt.Println(err)
+		exit(exitError)
+	}
+	defer f.Close()
+
+	parse := parseINIInventory
+	if strings.ToLower(filepath.Ext(fn)) == ".csv" {
+		parse = parseCSVInventory
+	}
+
+	types, err := parse(f)
+	if err != nil {
+		fmt.Printf("Could not read %s: %s\n", fn, err)
+		exit(exitError)
+	}
+
+	data, err := yaml.Marshal(HostInfo{
+		RawType:    "host",
+		RawSummary: "Imported from " + filepath.Base(fn),
+		Types:      types,
+	})
+	if err != nil {
+		fmt.Println(err)
+		exit(exitError)
+	}
+
+	if out == "" {
+		os.Stdout.Write(data)
+	} else {
+		if _, err := os.Stat(out); err == nil {
+			fmt.Println(out, "already exists")
+			exit(exitError)
+		}
+		if err := ioutil.WriteFile(out, data, 0644); err != nil {
+			fmt.Println(err)
+			exit(exitError)
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, importReport(types))
+}
+
+// importReport describes how many hosts went into which categories
+func importReport(types HostType) string {
+	keys := make([]string, 0, len(types))
+	total := 0
+	for key, cat := range types {
+		keys = append(keys, key)
+		total += len(cat.Hosts)
+	}
+	sort.Strings(keys)
+
+	counts := make([]string, len(keys))
+	for x, key := range keys {
+		counts[x] = fmt.Sprintf("%s (%d)", key, len(types[key].Hosts))
+	}
+
+	return fmt.Sprintf(
+		"Imported %d hosts into %d categories: %s",
+		total, len(keys), strings.Join(counts, ", "),
+	)
+}
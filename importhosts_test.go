@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestParseINIInventory(t *testing.T) {
+	assert := assert.New(t)
+	inventory := `
+bastion.company.net
+
+# The web servers
+[web]
+web1.company.net
+web2 ansible_host=web2.company.net ansible_user=deploy ansible_port=2222
+
+[web:vars]
+http_port=80
+
+[db]
+db1.company.net
+`
+
+	types, err := parseINIInventory(strings.NewReader(inventory))
+
+	assert.Nil(err)
+	assert.Equal(3, len(types))
+	assert.Equal([]Host{{FQDN: "bastion.company.net"}}, types["ungrouped"].Hosts)
+	assert.Equal([]Host{
+		{FQDN: "web1.company.net"},
+		{FQDN: "web2.company.net", SSHOptions: SSHOptions{User: "deploy", Port: 2222}},
+	}, types["web"].Hosts)
+	assert.Equal(
+		"Imported 4 hosts into 3 categories: db (1), ungrouped (1), web (2)",
+		importReport(types),
+	)
+}
+
+func TestParseCSVInventory(t *testing.T) {
+	assert := assert.New(t)
+	inventory := "group,fqdn,summary\nweb,web1.company.net,Frontend\ndb,db1.company.net\n"
+
+	types, err := parseCSVInventory(strings.NewReader(inventory))
+
+	assert.Nil(err)
+	assert.Equal([]Host{{FQDN: "web1.company.net", Summary: "Frontend"}}, types["web"].Hosts)
+	assert.Equal([]Host{{FQDN: "db1.company.net"}}, types["db"].Hosts)
+}
+
+func TestParseCSVInventoryWithoutFQDN(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := parseCSVInventory(strings.NewReader("web\n"))
+	assert.NotNil(err)
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/codegangsta/cli"
+	"github.com/fatih/color"
 	"github.com/tonnerre/golang-text"
 	"gopkg.in/yaml.v2"
-	"io/ioutil"
-	"log"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
 )
 
 // An Item is a representation of the YAML files in the repositories
@@ -18,55 +24,288 @@ type Item interface {
 	Type() string
 	Path() string
 	Summary() string
+	ModTime() time.Time
+}
+
+// flagger is implemented by items whose commands take flags
+type flagger interface {
+	Flags() []cli.Flag
 }
 
 // LoadItem loads an Info object from a file path
+//
+// Markdown files are always info items, with the Markdown as the body.
 func LoadItem(r *Repo, p string) (Item, error) {
-	data, err := ioutil.ReadFile(p)
+	var mtime time.Time
+	if fi, err := repoFS.Stat(p); err == nil {
+		mtime = fi.ModTime()
+	}
+
+	return readItem(r, p, mtime)
+}
+
+// readItem loads an item from a file whose modification time is already
+// known, like it is when a directory has been read
+func readItem(r *Repo, p string, mtime time.Time) (Item, error) {
+	data, err := repoFS.ReadFile(p)
 	if err != nil {
-		log.Fatal("Reading file failed: ", p)
+		return nil, fmt.Errorf("Reading file failed: %s", err)
 	}
 
-	// TODO(thiderman): Avoid the double unmarshal.
-	// Is there a way we can know some of the data in the stream before the unmarshal?
-	// Probably not?
-	i := &Info{id: asKey(p), path: p, repo: r}
-	yaml.Unmarshal(data, &i)
+	if filepath.Ext(p) == ".md" {
+		return loadMarkdown(r, p, data, mtime)
+	}
 
-	switch i.Type() {
+	// Most files are info items, so the file is read as one to find its
+	// kind. Only command and host files have to be read a second time.
+	i := &Info{id: asKey(p), path: p, mtime: mtime, repo: r}
+	if err := yaml.Unmarshal(data, &i); err != nil {
+		return nil, fmt.Errorf("%s: %s", p, err)
+	}
+
+	switch itemKind(i) {
 	case "command":
-		c := &Command{id: asKey(p), path: p, repo: r}
-		yaml.Unmarshal(data, &c)
+		c := &Command{id: asKey(p), path: p, mtime: mtime, repo: r}
+		if err := yaml.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("%s: %s", p, err)
+		}
 		return c, nil
 
 	case "host":
-		h := &HostInfo{id: asKey(p), path: p, repo: r}
-		yaml.Unmarshal(data, &h)
-		return h, nil
+		h := &HostInfo{id: asKey(p), path: p, mtime: mtime, repo: r}
+		if err := yaml.Unmarshal(data, &h); err != nil {
+			return nil, fmt.Errorf("%s: %s", p, err)
+		}
+		h.RawType = "host"
+		if r != nil {
+			h.resolve(r.Settings.SSHDefaults)
+		}
+		return h, h.validate()
 	}
 
-	yaml.Unmarshal(data, &i)
 	return i, nil
 }
 
+// itemKind returns what kind of item a file read as an Info really is:
+// "command", "host" or "info"
+//
+// Files that say `type: host` are hosts, and so are files without a type that
+// have `types:`, since that is where the hosts are. Any other type, including
+// ones that only repos know about, is an info item.
+func itemKind(i *Info) string {
+	switch i.RawType {
+	case "command", "host":
+		return i.RawType
+	case "":
+		if _, ok := i.Extra["types"]; ok {
+			return "host"
+		}
+	}
+	return "info"
+}
+
 // Info is the main storage for information. All yaml files map to this.
+//
+// Any keys in the file that are not fields of the struct end up in Extra, so
+// that repos can add whatever fields they need (owner, severity, ...). They
+// are available through Meta().
 type Info struct {
-	RawType    string `yaml:"type"`
-	RawSummary string `yaml:"summary"`
-	Body       string `yaml:"body"`
+	RawType    string                 `yaml:"type"`
+	RawSummary string                 `yaml:"summary,omitempty"`
+	Body       string                 `yaml:"body,omitempty"`
+	Sections   yaml.MapSlice          `yaml:"sections,omitempty"`
+	URL        string                 `yaml:"url,omitempty"`
+	RawOrder   *int                   `yaml:"order,omitempty"`
+	Secrets    map[string]string      `yaml:"secrets,omitempty"`
+	Extra      map[string]interface{} `yaml:",inline"`
+	secrets    map[string]string
 	id         string
 	path       string
+	mtime      time.Time
 	repo       *Repo
 }
 
+// infoActions maps the type of an Info to what executing it does. Types that
+// are not in here just print the body.
+//
+// Host and command files never end up here, since LoadItem already gives them
+// items of their own.
+var infoActions = map[string]func(i Info){
+	"note": Info.print,
+	"url":  Info.open,
+}
+
+// openURL opens a URL in the browser. It is a variable so that tests can avoid
+// launching one.
+var openURL = func(url string) error {
+	opener := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		opener = "open"
+	}
+
+	return exec.Command(opener, url).Start()
+}
+
 func (i Info) String() string {
 	return fmt.Sprintf("I: %s", i.ID())
 }
 
-// Execute will print the body
+// Execute does whatever the type of the item says, defaulting to printing the
+// body
+//
+// The secrets of the item are resolved first, and the names of them are listed
+// after the body. If a section is given, like `sagacity infra runbook fix`,
+// only that section is printed before them.
 func (i Info) Execute(c *cli.Context) {
-	out := text.Wrap(i.Body, 80)
-	fmt.Println(out)
+	secrets, err := resolveSecrets(i.Secrets)
+	if err != nil {
+		fmt.Println(err)
+		exit(exitError)
+	}
+	i.secrets = secrets
+
+	if c != nil && len(c.Args()) != 0 {
+		i.printSection(c.Args()[0])
+		return
+	}
+
+	action, ok := infoActions[i.Type()]
+	if !ok {
+		action = Info.print
+	}
+
+	action(i)
+
+	if next := i.pickLink(); next != nil {
+		next.Execute(nil)
+	}
+}
+
+// print prints the body and all of the sections, followed by the items they
+// link to. Execute then offers to show one of those.
+func (i Info) print() {
+	found, missing := i.resolveLinks()
+	page(i.text() + i.secretList() + i.footer() + i.linkList(found, missing))
+}
+
+// secretList lists the names of the secrets that Execute resolved, if there
+// are any. The values are only printed with --show-secrets, so that they do not
+// end up in the scrollback or in whatever the output is piped to.
+func (i Info) secretList() string {
+	if len(i.secrets) == 0 {
+		return ""
+	}
+
+	bold := color.New(color.Bold).SprintfFunc()
+	names := make([]string, 0, len(i.secrets))
+	for name := range i.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out bytes.Buffer
+	fmt.Fprintln(&out)
+	for _, name := range names {
+		switch {
+		case !showSecrets && plain:
+			fmt.Fprintln(&out, name)
+		case !showSecrets:
+			fmt.Fprintln(&out, bold(name))
+		case plain:
+			fmt.Fprintf(&out, "%s\t%s\n", name, i.secrets[name])
+		default:
+			fmt.Fprintf(&out, "%s: %s\n", bold(name), i.secrets[name])
+		}
+	}
+	return out.String()
+}
+
+// printSection prints one section of the item, exiting if there is no such
+// section
+func (i Info) printSection(name string) {
+	out, ok := i.sectionText(name)
+	if !ok {
+		if len(i.Sections) == 0 {
+			fmt.Println(i.ID(), "has no sections")
+		} else {
+			fmt.Println("No such section:", name)
+			fmt.Println("Choices are:", strings.Join(i.SectionNames(), ", "))
+		}
+		exit(exitNotFound)
+	}
+
+	page(out)
+}
+
+// sectionText returns one section of the item followed by the secrets and the
+// footer, the same way that text is followed by them when printing it all
+func (i Info) sectionText(name string) (string, bool) {
+	body, ok := i.Section(name)
+	if !ok {
+		return "", false
+	}
+	return renderLinks(i.wrap(body)) + "\n" + i.secretList() + i.footer(), true
+}
+
+// text returns the body followed by every section under a header of its own,
+// in the order of the file
+func (i Info) text() string {
+	bold := color.New(color.Bold).SprintfFunc()
+
+	var parts []string
+	if i.Body != "" {
+		parts = append(parts, i.wrap(i.Body))
+	}
+	for _, name := range i.SectionNames() {
+		body, _ := i.Section(name)
+		header := bold(name)
+		if plain {
+			header = name + ":"
+		}
+		parts = append(parts, header+"\n"+i.wrap(body))
+	}
+
+	return renderLinks(strings.Join(parts, "\n\n")) + "\n"
+}
+
+// wrap wraps text to fit the screen, unless the output is plain
+func (i Info) wrap(s string) string {
+	if plain {
+		return s
+	}
+	return text.Wrap(s, 80)
+}
+
+// SectionNames returns the names of the sections in the order of the file
+func (i Info) SectionNames() []string {
+	names := make([]string, 0, len(i.Sections))
+	for _, s := range i.Sections {
+		names = append(names, fmt.Sprint(s.Key))
+	}
+	return names
+}
+
+// Section returns the text of a section
+func (i Info) Section(name string) (string, bool) {
+	for _, s := range i.Sections {
+		if fmt.Sprint(s.Key) == name {
+			return strings.TrimSpace(fmt.Sprint(s.Value)), true
+		}
+	}
+	return "", false
+}
+
+// open opens the URL of the item in the browser
+func (i Info) open() {
+	if i.URL == "" {
+		fmt.Println("No url set in", i.Path())
+		exit(exitNotFound)
+	}
+
+	if err := openURL(i.URL); err != nil {
+		fmt.Println("Opening the browser failed:", err)
+		exit(exitError)
+	}
 }
 
 // MakeCLI makes a dummy CLI - Info items have no subcommands
@@ -93,3 +332,55 @@ func (i Info) Path() string {
 func (i Info) Summary() string {
 	return i.RawSummary
 }
+
+// Order returns the position the item wants in listings, if it has one
+func (i Info) Order() (int, bool) {
+	if i.RawOrder == nil {
+		return 0, false
+	}
+	return *i.RawOrder, true
+}
+
+// Meta returns an arbitrary field from the item's file, or nil if it has no
+// such field
+func (i Info) Meta(key string) interface{} {
+	return i.Extra[key]
+}
+
+// Archived returns true if the item is marked `archived: true` or
+// `status: deprecated`
+func (i Info) Archived() bool {
+	return i.Meta("archived") == true || i.Meta("status") == "deprecated"
+}
+
+// Related returns the keys of the items listed as `related`, which is either
+// one key or a list of them
+func (i Info) Related() (keys []string) {
+	switch rel := i.Extra["related"].(type) {
+	case string:
+		keys = []string{rel}
+	case []interface{}:
+		for _, key := range rel {
+			if s, ok := key.(string); ok {
+				keys = append(keys, s)
+			}
+		}
+	}
+	return
+}
+
+// Tags returns the tags listed in the item's file, if it has any
+func (i Info) Tags() (tags []string) {
+	list, _ := i.Extra["tags"].([]interface{})
+	for _, tag := range list {
+		if s, ok := tag.(string); ok {
+			tags = append(tags, s)
+		}
+	}
+	return
+}
+
+// ModTime returns the modification time of the item's file
+func (i Info) ModTime() time.Time {
+	return i.mtime
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"fmt"
 	"github.com/codegangsta/cli"
+	"github.com/fatih/color"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
 	"log"
 	"testing"
 )
@@ -45,6 +47,42 @@ func TestLoadInfo(t *testing.T) {
 	assert.Equal(i.Type(), "info")
 }
 
+func TestLoadItemUnreadable(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := LoadItem(&Repo{}, "test/data/missing.yaml")
+	assert.Contains(err.Error(), "Reading file failed")
+}
+
+func TestLoadItemDetectsHosts(t *testing.T) {
+	assert := assert.New(t)
+	defer useFS(memFS{
+		"/mem/typed.yaml":   "type: host\ntypes:\n  web:\n    hosts:\n      - fqdn: web1\n",
+		"/mem/untyped.yaml": "summary: Frontends\ntypes:\n  web:\n    hosts:\n      - fqdn: web1\n",
+		"/mem/info.yaml":    "type: info\ntypes: [a, b]\n",
+		"/mem/plain.yaml":   "summary: Nothing special\n",
+	})()
+
+	for fn, kind := range map[string]string{
+		"/mem/typed.yaml":   "host",
+		"/mem/untyped.yaml": "host",
+		"/mem/info.yaml":    "info",
+		"/mem/plain.yaml":   "info",
+	} {
+		i, err := LoadItem(&Repo{}, fn)
+		assert.Nil(err, fn)
+
+		_, isHost := i.(*HostInfo)
+		_, isInfo := i.(*Info)
+		assert.Equal(kind == "host", isHost, fn)
+		assert.Equal(kind == "info", isInfo, fn)
+	}
+
+	i, _ := LoadItem(&Repo{}, "/mem/untyped.yaml")
+	assert.Equal("host", i.Type())
+	assert.Equal("web1", i.(*HostInfo).Types["web"].Hosts[0].FQDN)
+}
+
 // // Executing an info item is just supposed to print the contents.
 // func ExampleExecuteInfo() {
 // 	i := loadTestFile("ExecuteInfo")
@@ -59,3 +97,105 @@ func TestLoadInfo(t *testing.T) {
 // 	i.Execute(repo, ctx)
 // 	// Output: Should there be a 4chan ipsum?
 // }
+
+func TestInfoExecuteOpensURLs(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func(orig func(string) error) { openURL = orig }(openURL)
+	var opened string
+	openURL = func(url string) error {
+		opened = url
+		return nil
+	}
+
+	i := Info{RawType: "url", URL: "https://example.com/runbook"}
+	i.Execute(nil)
+
+	assert.Equal("https://example.com/runbook", opened)
+}
+
+func ExampleInfo_Execute() {
+	Info{RawType: "note", Body: "Notes are printed"}.Execute(nil)
+	Info{RawType: "mystery", Body: "So are unknown types"}.Execute(nil)
+	// Output: Notes are printed
+	// So are unknown types
+}
+
+func TestInfoCapturesArbitraryMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	item, _ := LoadItem(&Repo{}, "test/meta/runbook.yaml")
+	i := item.(*Info)
+
+	assert.Equal("payments-team", i.Meta("owner"))
+	assert.Equal(2, i.Meta("severity"))
+	assert.Equal("2016-02-01", i.Meta("last-reviewed"))
+	assert.Nil(i.Meta("missing"))
+
+	// Known fields are not duplicated into the metadata
+	assert.Nil(i.Meta("body"))
+	assert.Equal("Turn it off and on again.", i.Body)
+}
+
+func TestInfoMetadataRoundTrips(t *testing.T) {
+	assert := assert.New(t)
+
+	item, _ := LoadItem(&Repo{}, "test/meta/runbook.yaml")
+	data, err := yaml.Marshal(item)
+	assert.Nil(err)
+
+	var i Info
+	yaml.Unmarshal(data, &i)
+
+	assert.Equal("payments-team", i.Meta("owner"))
+	assert.Equal(2, i.Meta("severity"))
+	assert.Equal("Restarting the payment service", i.Summary())
+}
+
+func TestInfoSections(t *testing.T) {
+	assert := assert.New(t)
+
+	item, _ := LoadItem(&Repo{}, "test/sections/outage.yaml")
+	i := item.(*Info)
+
+	assert.Equal([]string{"symptoms", "fix"}, i.SectionNames())
+
+	fix, ok := i.Section("fix")
+	assert.True(ok)
+	assert.Equal("Restart the service.", fix)
+
+	_, ok = i.Section("cause")
+	assert.False(ok)
+}
+
+func TestInfoTextShowsAllSections(t *testing.T) {
+	assert := assert.New(t)
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	item, _ := LoadItem(&Repo{}, "test/sections/outage.yaml")
+
+	assert.Equal(
+		"Start here.\n\nsymptoms\nPayments time out.\n\nfix\nRestart the service.\n",
+		item.(*Info).text(),
+	)
+}
+
+func TestInfoSectionTextListsSecrets(t *testing.T) {
+	assert := assert.New(t)
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	item, _ := LoadItem(&Repo{}, "test/sections/outage.yaml")
+	i := item.(*Info)
+	i.secrets = map[string]string{"DB_PASSWORD": "hunter2"}
+
+	out, ok := i.sectionText("fix")
+	assert.True(ok)
+	assert.Equal("Restart the service.\n\nDB_PASSWORD\n", out)
+
+	_, ok = i.sectionText("cause")
+	assert.False(ok)
+}
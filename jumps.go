@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// jumpTargets returns the FQDNs of the hosts in the file that a host jumps
+// through, before the jumps are resolved into what ssh gets
+//
+// A jump is either the name of a category, meaning its primary host, or the
+// [user@]fqdn[:port] of a host. Jumps to hosts outside of the file are left
+// out, since they cannot jump back into it.
+func (h HostType) jumpTargets(host Host, known map[string]bool) (targets []string) {
+	jumps := host.Jumps
+	if jumps == nil && host.Jump != "" {
+		jumps = []string{host.Jump}
+	}
+
+	for _, jump := range jumps {
+		if cat, ok := h[jump]; ok {
+			if len(cat.Hosts) != 0 {
+				targets = append(targets, cat.PrimaryHost().FQDN)
+			}
+			continue
+		}
+
+		if fqdn := jumpFQDN(jump); known[fqdn] {
+			targets = append(targets, fqdn)
+		}
+	}
+
+	return
+}
+
+// jumpFQDN returns the FQDN of a [user@]fqdn[:port] jump
+func jumpFQDN(jump string) string {
+	if x := strings.LastIndex(jump, "@"); x >= 0 {
+		jump = jump[x+1:]
+	}
+	if x := strings.Index(jump, ":"); x >= 0 {
+		jump = jump[:x]
+	}
+	return jump
+}
+
+// jumpCycle returns the first chain of hosts that ends up jumping through
+// itself, like [a b a] when a jumps through b and b through a. It is nil if
+// there are no cycles.
+//
+// ssh would either refuse such a chain or go around in circles, so it is
+// better to tell the user what they have done.
+func (h HostType) jumpCycle() []string {
+	hosts := make(map[string]Host)
+	known := make(map[string]bool)
+	var order []string
+	for _, host := range h.UniqueHosts() {
+		hosts[host.FQDN] = host
+		known[host.FQDN] = true
+		order = append(order, host.FQDN)
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+
+	var path []string
+	var visit func(fqdn string) []string
+	visit = func(fqdn string) []string {
+		state[fqdn] = visiting
+		path = append(path, fqdn)
+
+		for _, next := range h.jumpTargets(hosts[fqdn], known) {
+			switch state[next] {
+			case visiting:
+				for x, p := range path {
+					if p == next {
+						return append(append([]string{}, path[x:]...), next)
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[fqdn] = done
+		return nil
+	}
+
+	for _, fqdn := range order {
+		if state[fqdn] == unvisited {
+			if cycle := visit(fqdn); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// JumpCycles returns a description of every host file in the repository and
+// its subrepos whose hosts jump through each other in a circle
+func (r *Repo) JumpCycles() (problems []string) {
+	for _, h := range r.HostInfos() {
+		if h.cycle != nil {
+			problems = append(problems, fmt.Sprintf(
+				"%s: jump cycle %s", h.Path(), strings.Join(h.cycle, " -> "),
+			))
+		}
+	}
+	return
+}
+
+// knownHosts returns the FQDNs of every host in every repo
+func knownHosts(repos map[string]*Repo) map[string]bool {
+	known := make(map[string]bool)
+	for _, r := range repos {
+		for _, h := range r.HostInfos() {
+			for _, host := range h.Types.UniqueHosts() {
+				known[host.FQDN] = true
+			}
+		}
+	}
+	return known
+}
+
+// DanglingReferences describes the references in the repository and its
+// subrepos that point at nothing: jumps through hosts that are not in any
+// repo, and `related` items that do not exist
+//
+// Jumps are already resolved by the time they get here, so jumps to categories
+// have become the hosts they point at.
+func (r *Repo) DanglingReferences(known map[string]bool) (problems []string) {
+	for _, h := range r.HostInfos() {
+		seen := make(map[string]bool)
+		for _, host := range h.Types.UniqueHosts() {
+			jumps := host.Jumps
+			if jumps == nil && host.Jump != "" {
+				jumps = []string{host.Jump}
+			}
+
+			for _, jump := range jumps {
+				if fqdn := jumpFQDN(jump); !known[fqdn] && !seen[fqdn] {
+					seen[fqdn] = true
+					problems = append(problems, fmt.Sprintf("%s: jump through unknown host %s", h.Path(), fqdn))
+				}
+			}
+		}
+	}
+
+	problems = append(problems, r.danglingRelated()...)
+	return
+}
+
+// danglingRelated describes the `related` items of info items in the
+// repository and its subrepos that do not exist. They are looked up the same
+// way as [[links]].
+func (r *Repo) danglingRelated() (problems []string) {
+	for _, key := range r.Keys() {
+		item, _ := r.Item(key)
+		info, ok := item.(*Info)
+		if !ok {
+			continue
+		}
+
+		for _, rel := range info.Related() {
+			if _, ok := r.findLink(rel); !ok {
+				problems = append(problems, fmt.Sprintf("%s: related item %s does not exist", info.Path(), rel))
+			}
+		}
+	}
+
+	for _, key := range r.SubrepoKeys() {
+		sub, _ := r.Subrepo(key)
+		problems = append(problems, sub.danglingRelated()...)
+	}
+
+	return
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJumpCycle(t *testing.T) {
+	assert := assert.New(t)
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	r := NewRepo("test/jumpcycle/")
+	h := r.Items["hosts"].(*HostInfo)
+
+	assert.Equal([]string{"inner.company.net", "outer.company.net", "inner.company.net"}, h.cycle)
+	assert.Contains(h.validate().Error(), "inner.company.net -> outer.company.net -> inner.company.net")
+	assert.Equal(1, len(r.JumpCycles()))
+}
+
+func TestJumpCycleWithoutCycle(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewRepo("test/jumps/")
+
+	assert.Nil(r.Items["hosts"].(*HostInfo).cycle)
+	assert.Equal(0, len(r.JumpCycles()))
+}
+
+func TestDanglingReferences(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewRepo("test/dangling/")
+	hosts, _ := filepath.Abs("test/dangling/hosts.yaml")
+	runbook, _ := filepath.Abs("test/dangling/runbook.yaml")
+	known := knownHosts(map[string]*Repo{"dangling": r})
+
+	assert.Equal([]string{
+		hosts + ": jump through unknown host gone.company.net",
+		runbook + ": related item nowhere does not exist",
+	}, r.DanglingReferences(known))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/fatih/color"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FindByKind returns every host of a kind in the host file
+func (h *HostInfo) FindByKind(kind string) (matches []HostMatch) {
+	for _, key := range h.Types.List() {
+		for x, host := range h.Types[key].Hosts {
+			if host.Kind == kind {
+				matches = append(matches, HostMatch{h, key, x, host})
+			}
+		}
+	}
+
+	return
+}
+
+// FindKind returns the hosts of a kind in every repo, by the key of the repo.
+// Repos without any are left out.
+func FindKind(repos map[string]*Repo, kind string) map[string][]HostMatch {
+	found := make(map[string][]HostMatch)
+	for key, r := range repos {
+		for _, h := range r.HostInfos() {
+			if matches := h.FindByKind(kind); len(matches) != 0 {
+				found[key] = append(found[key], matches...)
+			}
+		}
+	}
+	return found
+}
+
+// Kinds returns a sorted list of the kinds of every host in the repos
+func Kinds(repos map[string]*Repo) []string {
+	seen := make(map[string]bool)
+	for _, r := range repos {
+		for _, h := range r.HostInfos() {
+			for _, host := range h.Types.Hosts() {
+				if host.Kind != "" {
+					seen[host.Kind] = true
+				}
+			}
+		}
+	}
+
+	kinds := make([]string, 0, len(seen))
+	for kind := range seen {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// ListKind prints the hosts of a kind in every repo, like all the databases
+func ListKind(repos map[string]*Repo, kind string) {
+	found := FindKind(repos, kind)
+	if len(found) == 0 {
+		fmt.Printf("No hosts are of the kind %s. Known kinds are: %s\n", kind, strings.Join(Kinds(repos), ", "))
+		exit(exitNotFound)
+	}
+
+	page(kindListing(repos, found))
+}
+
+// kindListing formats the hosts under their repo, and then under the host
+// file and category they are in. Plain output has one host per line, with the
+// repo, the file, the category and the FQDN separated by tabs.
+func kindListing(repos map[string]*Repo, found map[string][]HostMatch) string {
+	cyan := color.New(color.FgCyan, color.Bold).SprintfFunc()
+	blue := color.New(color.FgBlue, color.Bold).SprintfFunc()
+	grey := color.New(color.FgWhite).SprintfFunc()
+
+	keys := make([]string, 0, len(found))
+	for key := range found {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var out bytes.Buffer
+	for _, key := range keys {
+		if !plain {
+			fmt.Fprintln(&out, repos[key].label(key))
+		}
+
+		last := ""
+		for _, m := range found[key] {
+			fn, err := filepath.Rel(repos[key].root, m.Info.Path())
+			if err != nil {
+				fn = m.Info.Path()
+			}
+
+			if plain {
+				fmt.Fprintf(&out, "%s\t%s\t%s\t%s\n", key, fn, m.Category, m.Host.FQDN)
+				continue
+			}
+
+			if group := fn + " " + m.Category; group != last {
+				fmt.Fprintf(&out, "  %s %s\n", fn, cyan(m.Category))
+				last = group
+			}
+			fmt.Fprintf(&out, "    %s", blue(m.Host.FQDN))
+			if m.Host.Summary != "" {
+				fmt.Fprintf(&out, " (%s)", grey(m.Host.Summary))
+			}
+			fmt.Fprintln(&out)
+		}
+	}
+
+	return out.String()
+}
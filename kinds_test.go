@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func kindRepos() map[string]*Repo {
+	return map[string]*Repo{
+		"one":   NewRepo("test/kinds/"),
+		"two":   NewRepo("test/kinds2/"),
+		"empty": NewRepo("test/order/"),
+	}
+}
+
+func TestFindKindAcrossRepos(t *testing.T) {
+	assert := assert.New(t)
+
+	found := FindKind(kindRepos(), "postgres")
+
+	assert.Equal(2, len(found))
+	assert.Equal(1, len(found["one"]))
+	assert.Equal("db1.company.net", found["one"][0].Host.FQDN)
+	assert.Equal(2, len(found["two"]))
+	assert.Equal("analytics", found["two"][1].Category)
+
+	assert.Equal(0, len(FindKind(kindRepos(), "mainframe")))
+	assert.Equal([]string{"canary", "frontend", "postgres"}, Kinds(kindRepos()))
+}
+
+func TestKindListingPlain(t *testing.T) {
+	assert := assert.New(t)
+	noColor := color.NoColor
+	defer func() {
+		plain = false
+		color.NoColor = noColor
+	}()
+	setPlain()
+
+	repos := kindRepos()
+	out := kindListing(repos, FindKind(repos, "postgres"))
+
+	assert.Equal(
+		"one\thosts.yaml\tdb\tdb1.company.net\n"+
+			"two\tdc2/hosts.yaml\tanalytics\twarehouse1.dc2.company.net\n"+
+			"two\tdc2/hosts.yaml\tanalytics\twarehouse2.dc2.company.net\n",
+		out,
+	)
+}
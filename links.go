@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/fatih/color"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// linkRxp matches links to other items in bodies, like [[failover]] or
+// [[dns/zones]]
+var linkRxp = regexp.MustCompile(`\[\[([^\[\]\s]+)\]\]`)
+
+// Links returns the keys that the body and sections link to, in the order they
+// first appear
+func (i Info) Links() (links []string) {
+	texts := []string{i.Body}
+	for _, name := range i.SectionNames() {
+		s, _ := i.Section(name)
+		texts = append(texts, s)
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range texts {
+		for _, m := range linkRxp.FindAllStringSubmatch(t, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				links = append(links, m[1])
+			}
+		}
+	}
+
+	return
+}
+
+// findLink finds the item that a link points at
+//
+// Links are paths of keys separated by slashes, relative to the repo of the
+// item. If there is no such item there, the parents of the repo are tried, the
+// closest one first.
+//
+// GetItem is not used, since it logs the repo whenever nothing matches, and
+// links that do not resolve in the closest repo are expected.
+func (r *Repo) findLink(link string) (Item, bool) {
+	item, _, ok := r.resolveLink(link)
+	return item, ok
+}
+
+// resolveLink is findLink, also returning the repo that the item is in
+func (r *Repo) resolveLink(link string) (Item, *Repo, bool) {
+	for repo := r; repo != nil; repo = repo.Parent {
+		sub, remaining, err := repo.GetSubrepo(strings.Split(link, "/"))
+		if err != nil || len(remaining) != 1 {
+			continue
+		}
+		if item, ok := sub.Item(remaining[0]); ok {
+			return item, sub, true
+		}
+	}
+	return nil, nil, false
+}
+
+// resolveLinks returns the items that the links point at, and the links that
+// do not point at anything
+func (i Info) resolveLinks() (found []Item, missing []string) {
+	for _, link := range i.Links() {
+		if i.repo == nil {
+			missing = append(missing, link)
+			continue
+		}
+
+		if item, ok := i.repo.findLink(link); ok {
+			found = append(found, item)
+		} else {
+			missing = append(missing, link)
+		}
+	}
+
+	return
+}
+
+// renderLinks highlights the links in a text
+func renderLinks(s string) string {
+	if plain {
+		return s
+	}
+
+	cyan := color.New(color.FgCyan, color.Underline).SprintfFunc()
+	return linkRxp.ReplaceAllStringFunc(s, func(m string) string {
+		return cyan(m[2 : len(m)-2])
+	})
+}
+
+// linkList lists the items that the item links to, numbered so that one can
+// be picked, and the links that are broken
+func (i Info) linkList(found []Item, missing []string) string {
+	if len(found) == 0 && len(missing) == 0 {
+		return ""
+	}
+
+	yellow := color.New(color.FgYellow).SprintfFunc()
+	hiyellow := color.New(color.FgHiYellow, color.Bold).SprintfFunc()
+	red := color.New(color.FgRed).SprintfFunc()
+
+	var b bytes.Buffer
+	b.WriteString("\nLinks:\n")
+	for x, item := range found {
+		if plain {
+			fmt.Fprintf(&b, "%s\t%s\n", item.ID(), item.Summary())
+			continue
+		}
+		fmt.Fprintf(&b, "  %s%s%s %s  %s\n", yellow("["), hiyellow("%d", x), yellow("]"), item.ID(), item.Summary())
+	}
+	for _, link := range missing {
+		fmt.Fprintf(&b, "  %s\n", red("%s (no such item)", link))
+	}
+
+	return b.String()
+}
+
+// pickLink asks which of the linked items to show next, if there is anyone
+// there to ask. Only info items can be picked, since the others need
+// arguments of their own.
+func (i Info) pickLink() *Info {
+	if plain || !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return nil
+	}
+
+	found, _ := i.resolveLinks()
+	if len(found) == 0 {
+		return nil
+	}
+
+	x, ok := choose(fmt.Sprintf("Follow a link? [0-%d] ", len(found)-1), len(found))
+	if !ok {
+		return nil
+	}
+	info, _ := found[x].(*Info)
+	return info
+}
+
+// BrokenLinks describes the links in the repository and its subrepos that do
+// not point at any item
+func (r *Repo) BrokenLinks() (problems []string) {
+	for _, key := range r.Keys() {
+		item, _ := r.Item(key)
+		info, ok := item.(*Info)
+		if !ok {
+			continue
+		}
+
+		_, missing := info.resolveLinks()
+		for _, link := range missing {
+			problems = append(problems, fmt.Sprintf("%s: link to unknown item [[%s]]", info.Path(), link))
+		}
+	}
+
+	for _, key := range r.SubrepoKeys() {
+		sub, _ := r.Subrepo(key)
+		problems = append(problems, sub.BrokenLinks()...)
+	}
+
+	return
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestInfoLinks(t *testing.T) {
+	assert := assert.New(t)
+	i := Info{
+		Body: "See [[dns/zones]] and [[failover]], and [[dns/zones]] again. [[not a link]]",
+	}
+
+	assert.Equal([]string{"dns/zones", "failover"}, i.Links())
+}
+
+func TestResolveLinks(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/links/")
+
+	failover := r.Items["failover"].(*Info)
+	found, missing := failover.resolveLinks()
+	assert.Equal(1, len(found))
+	assert.Equal("zones", found[0].ID())
+	assert.Equal([]string{"nowhere"}, missing)
+
+	// Links that are not in the repo of the item are looked up in its parents
+	zones := r.Subrepos["dns"].Items["zones"].(*Info)
+	found, missing = zones.resolveLinks()
+	assert.Equal("failover", found[0].ID())
+	assert.Nil(missing)
+}
+
+func TestBrokenLinks(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/links/")
+
+	problems := r.BrokenLinks()
+
+	assert.Equal(1, len(problems))
+	assert.Contains(problems[0], "link to unknown item [[nowhere]]")
+}
+
+func TestFindLinkDoesNotLog(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/links/")
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	_, ok := r.Subrepos["dns"].findLink("nowhere/at/all")
+	assert.False(ok)
+	assert.Equal("", buf.String())
+}
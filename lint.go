@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"github.com/fatih/color"
+	"sort"
+)
+
+// controlFiles are the control files that sagacity knows what to do with.
+// Anything else starting with an underscore is loaded, but never used.
+var controlFiles = map[string]bool{
+	"_repo": true,
+}
+
+// UnknownControl returns the paths of the control files in the repository and
+// all of its subrepos that are not in controlFiles, such as a misspelled
+// _rep.yaml whose settings would silently be ignored
+func (r *Repo) UnknownControl() (paths []string) {
+	r.mu.RLock()
+	for key, item := range r.Control {
+		if !controlFiles[key] {
+			paths = append(paths, item.Path())
+		}
+	}
+	r.mu.RUnlock()
+	sort.Strings(paths)
+
+	for _, key := range r.SubrepoKeys() {
+		sub, _ := r.Subrepo(key)
+		paths = append(paths, sub.UnknownControl()...)
+	}
+
+	return
+}
+
+// Lint reports problems in the repos that do not stop them from loading, but
+// that probably mean that something is not doing what it should
+//
+// If a key is given, only that repo is checked. The process exits non-zero if
+// anything was found.
+func Lint(repos map[string]*Repo, key string) {
+	yellow := color.New(color.FgYellow).SprintfFunc()
+	green := color.New(color.FgGreen, color.Bold).SprintfFunc()
+
+	keys := repoKeys(repos, key)
+
+	known := knownHosts(repos)
+
+	count := 0
+	for _, k := range keys {
+		for _, p := range repos[k].UnknownControl() {
+			fmt.Println(yellow("%s: unknown control file, it is not used", p))
+			count++
+		}
+		for _, problem := range repos[k].JumpCycles() {
+			fmt.Println(yellow(problem))
+			count++
+		}
+		for _, problem := range repos[k].BrokenLinks() {
+			fmt.Println(yellow(problem))
+			count++
+		}
+		for _, problem := range repos[k].DanglingReferences(known) {
+			fmt.Println(yellow(problem))
+			count++
+		}
+	}
+
+	if count != 0 {
+		fmt.Printf("%d problems found\n", count)
+		exit(exitError)
+	}
+	fmt.Println(green("No problems found"))
+}
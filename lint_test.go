@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnknownControl(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/lint/")
+	rep, _ := filepath.Abs("test/lint/_rep.yaml")
+	notes, _ := filepath.Abs("test/lint/sub/_notes.yaml")
+
+	assert.Equal([]string{rep, notes}, r.UnknownControl())
+}
+
+func TestUnknownControlWithoutAny(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/order/")
+
+	assert.Equal(0, len(r.UnknownControl()))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"github.com/fatih/color"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Manifest is a list of repositories that make up a knowledge base
+//
+// It lets a whole setup be reproduced on a new machine by syncing it.
+type Manifest []ManifestEntry
+
+// ManifestEntry is one repository in a manifest
+type ManifestEntry struct {
+	Name   string `yaml:"name"`
+	URL    string `yaml:"url"`
+	Branch string `yaml:"branch"`
+}
+
+// LoadManifest loads a manifest file
+func LoadManifest(fn string) (Manifest, error) {
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %s", fn, err)
+	}
+
+	return m, nil
+}
+
+// Dir returns the directory the repository is cloned into
+//
+// If the entry has no name, it is made from the last part of the URL without
+// its `.git` suffix, stripped of prefixes the same way that `repo add` does it.
+func (e ManifestEntry) Dir(root string) string {
+	name := e.Name
+	if name == "" {
+		name = repoName(strings.TrimSuffix(path.Base(e.URL), ".git"))
+	}
+
+	return filepath.Join(root, name)
+}
+
+// Missing returns the entries that have not been cloned into the root
+func (m Manifest) Missing(root string) (missing []ManifestEntry) {
+	for _, e := range m {
+		if !isDir(e.Dir(root)) {
+			missing = append(missing, e)
+		}
+	}
+
+	return
+}
+
+// SyncManifest clones every repository in the manifest that is not present
+//
+// Every repository is attempted even if some of them fail, and the failures
+// are reported at the end. It returns the code to exit with: exitError if the
+// manifest could not be read, exitGitError if any clone failed and 0 otherwise.
+func SyncManifest(conf *Config, fn string) int {
+	red := color.New(color.FgRed, color.Bold).SprintfFunc()
+	green := color.New(color.FgGreen, color.Bold).SprintfFunc()
+
+	m, err := LoadManifest(fn)
+	if err != nil {
+		fmt.Println(red("Could not load manifest: %s", err))
+		return exitError
+	}
+
+	var failed []ManifestEntry
+	missing := m.Missing(conf.RepoRoot)
+	for _, e := range missing {
+		args := []string{"clone", "--origin", conf.remote(), e.URL, e.Dir(conf.RepoRoot)}
+		if e.Branch != "" {
+			args = append(args, "--branch", e.Branch)
+		}
+
+		if err := gitRun("", args...); err != nil {
+			failed = append(failed, e)
+		}
+	}
+
+	if len(failed) == 0 {
+		fmt.Println(green("Cloned %d of %d repos in the manifest", len(missing), len(m)))
+		return 0
+	}
+
+	fmt.Println(red("%d repos failed to clone:", len(failed)))
+	for _, e := range failed {
+		fmt.Printf("  %s\n", e.URL)
+	}
+
+	return exitGitError
+}
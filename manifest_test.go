@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadManifest(t *testing.T) {
+	assert := assert.New(t)
+
+	m, err := LoadManifest("test/manifest.yaml")
+
+	assert.Nil(err)
+	assert.Equal(3, len(m))
+	assert.Equal("develop", m[2].Branch)
+	assert.Equal(filepath.Join("test", "sshdefaults"), m[1].Dir("test"))
+}
+
+func TestManifestMissing(t *testing.T) {
+	assert := assert.New(t)
+	m, _ := LoadManifest("test/manifest.yaml")
+
+	missing := m.Missing("test")
+
+	assert.Equal(1, len(missing))
+	assert.Equal("nowhere", missing[0].Name)
+}
+
+func TestConfigRepoDirsIncludesClonedManifestRepos(t *testing.T) {
+	assert := assert.New(t)
+	c := &Config{
+		RepoRoot:     "test",
+		Repositories: []string{filepath.Join("test", "inherit")},
+		Manifest:     "test/manifest.yaml",
+	}
+
+	// inherit is both configured and in the manifest, and nowhere is not
+	// cloned
+	assert.Equal([]string{
+		filepath.Join("test", "inherit"),
+		filepath.Join("test", "sshdefaults"),
+	}, c.RepoDirs())
+}
+
+func TestManifestEntryDirFromURL(t *testing.T) {
+	assert := assert.New(t)
+
+	e := ManifestEntry{URL: "git@github.com:some-org/saga-topic.git"}
+	assert.Equal(filepath.Join("root", "topic"), e.Dir("root"))
+
+	e = ManifestEntry{URL: "https://example.com/notes"}
+	assert.Equal(filepath.Join("root", "notes"), e.Dir("root"))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"gopkg.in/yaml.v2"
+	"time"
+)
+
+// frontMatterDelim starts and ends the YAML front matter of Markdown files
+var frontMatterDelim = []byte("---")
+
+// splitFrontMatter splits a Markdown file into its YAML front matter and the
+// rest of it. If it does not start with front matter, all of it is the rest.
+func splitFrontMatter(data []byte) (front, rest []byte) {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if len(lines) == 0 || !bytes.Equal(bytes.TrimSpace(lines[0]), frontMatterDelim) {
+		return nil, data
+	}
+
+	for x := 1; x < len(lines); x++ {
+		if bytes.Equal(bytes.TrimSpace(lines[x]), frontMatterDelim) {
+			return bytes.Join(lines[1:x], nil), bytes.Join(lines[x+1:], nil)
+		}
+	}
+
+	// No end to the front matter, so it was not front matter after all
+	return nil, data
+}
+
+// loadMarkdown loads an Info from a Markdown file
+//
+// The front matter has the same fields as an info YAML file, and the Markdown
+// after it is the body. Files without front matter only get a body.
+func loadMarkdown(r *Repo, p string, data []byte, mtime time.Time) (Item, error) {
+	i := &Info{id: asKey(p), path: p, mtime: mtime, repo: r}
+
+	front, rest := splitFrontMatter(data)
+	if front != nil {
+		if err := yaml.Unmarshal(front, i); err != nil {
+			return i, err
+		}
+	}
+
+	i.Body = string(bytes.TrimSpace(rest))
+	return i, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLoadMarkdownWithFrontMatter(t *testing.T) {
+	assert := assert.New(t)
+
+	item, err := LoadItem(&Repo{}, "test/markdown/deploy.md")
+	i := item.(*Info)
+
+	assert.Nil(err)
+	assert.Equal("deploy", i.ID())
+	assert.Equal("runbook", i.Type())
+	assert.Equal("Deploying the frontend", i.Summary())
+	assert.Equal("web-team", i.Meta("owner"))
+	assert.Equal("# Deploying\n\nRun `make deploy`.", i.Body)
+}
+
+func TestLoadMarkdownWithoutFrontMatter(t *testing.T) {
+	assert := assert.New(t)
+
+	item, err := LoadItem(&Repo{}, "test/markdown/scratch.md")
+	i := item.(*Info)
+
+	assert.Nil(err)
+	assert.Equal("scratch", i.ID())
+	assert.Equal("", i.Type())
+	assert.Equal("Just some notes,\nwithout any front matter.", i.Body)
+}
+
+func TestSplitFrontMatterWithoutEnd(t *testing.T) {
+	assert := assert.New(t)
+
+	front, rest := splitFrontMatter([]byte("---\nthis never ends\n"))
+
+	assert.Nil(front)
+	assert.Equal("---\nthis never ends\n", string(rest))
+}
+
+func TestRepoLoadsMarkdown(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewRepo("test/markdown/")
+
+	assert.Equal([]string{"deploy", "scratch"}, r.Keys())
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MoveItem moves an info item into another subrepo of the same repo, keeping
+// its key, and updates the links and `related` entries that point at it
+func MoveItem(repos map[string]*Repo, path, target string) {
+	info := findInfo(repos, path)
+	to, err := findSubrepo(repos, target)
+	if err != nil {
+		fmt.Println(err)
+		exit(exitNotFound)
+	}
+
+	root := info.repo.ParentRepo()
+	switch {
+	case to.ParentRepo() != root:
+		fmt.Printf("%s is not in the same repo as %s\n", target, path)
+		exit(exitError)
+	case to == info.repo:
+		fmt.Printf("%s is already in %s\n", path, target)
+		exit(exitError)
+	}
+
+	changes, err := relocate(info, to, info.ID())
+	if err != nil {
+		fmt.Println(err)
+		exit(exitError)
+	}
+
+	// Load the repo again to make sure that the item ended up where it should
+	link := linkTo(root, to, info.ID())
+	if _, ok := NewRepo(root.root).findLink(link); !ok {
+		fmt.Printf("Moved %s, but it cannot be found as %s/%s\n", path, root.Key, link)
+		exit(exitError)
+	}
+
+	fmt.Printf("Moved %s to %s/%s\n", path, root.Key, link)
+	printChanges(changes)
+}
+
+// findSubrepo returns the repo or subrepo at a path of keys like infra/dns
+func findSubrepo(repos map[string]*Repo, path string) (*Repo, error) {
+	keys := strings.Split(path, "/")
+	r, err := findRepo(repos, keys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	sub, remaining, err := r.GetSubrepo(keys[1:])
+	if err != nil || len(remaining) != 0 {
+		return nil, fmt.Errorf("No such subrepo: %s", path)
+	}
+	return sub, nil
+}
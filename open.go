@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// findRepo returns the repository with a key
+func findRepo(repos map[string]*Repo, key string) (*Repo, error) {
+	if r, ok := repos[key]; ok {
+		return r, nil
+	}
+
+	keys := make([]string, 0, len(repos))
+	for k := range repos {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return nil, fmt.Errorf("No such repo: %s. Choices are: %s", key, strings.Join(keys, ", "))
+}
+
+// repoKeys returns the sorted keys of the repositories to check, which is all
+// of them unless a key is given. It exits if there is no repo with that key.
+func repoKeys(repos map[string]*Repo, key string) []string {
+	keys := make([]string, 0, len(repos))
+	for k := range repos {
+		if key == "" || k == key {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		_, err := findRepo(repos, key)
+		fmt.Println(err)
+		exit(exitNotFound)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// OpenRepo prints the directory of a repository, so that `cd $(sp open-repo
+// infra)` works, or starts a shell in it if shell is set
+func OpenRepo(repos map[string]*Repo, key string, shell bool) {
+	r, err := findRepo(repos, key)
+	if err != nil {
+		fmt.Println(err)
+		exit(exitNotFound)
+	}
+
+	if !shell {
+		fmt.Println(r.root)
+		return
+	}
+
+	sh := os.Getenv("SHELL")
+	if sh == "" {
+		sh = "/bin/sh"
+	}
+
+	cmd := exec.Command(sh)
+	cmd.Dir = r.root
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// The exit status of the shell is whatever the last command in it
+	// returned, which is not interesting.
+	cmd.Run()
+}
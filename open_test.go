@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFindRepo(t *testing.T) {
+	assert := assert.New(t)
+	order := NewRepo("test/order/")
+	repos := map[string]*Repo{"order": order, "tags": NewRepo("test/tags/")}
+
+	r, err := findRepo(repos, "order")
+	assert.Nil(err)
+	assert.Equal(order, r)
+
+	_, err = findRepo(repos, "nope")
+	assert.EqualError(err, "No such repo: nope. Choices are: order, tags")
+}
+
+func TestRepoKeys(t *testing.T) {
+	assert := assert.New(t)
+	repos := map[string]*Repo{"tags": {}, "order": {}}
+
+	assert.Equal([]string{"order", "tags"}, repoKeys(repos, ""))
+	assert.Equal([]string{"tags"}, repoKeys(repos, "tags"))
+	assert.Equal(exitNotFound, exitCode(func() { repoKeys(repos, "nope") }))
+}
+
+func ExampleOpenRepo() {
+	repos := map[string]*Repo{"order": {root: "/srv/sagacity/order"}}
+
+	OpenRepo(repos, "order", false)
+	// Output: /srv/sagacity/order
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// noPager disables paging of long output everywhere
+var noPager = false
+
+// page prints output, sending it through $PAGER if it does not fit on screen
+//
+// Output is only ever paged when stdout is a terminal, so anything piped or
+// redirected gets the output as is.
+func page(out string) {
+	if noPager || !isTerminal(os.Stdout) {
+		fmt.Print(out)
+		return
+	}
+
+	if rows, _ := terminalSize(); strings.Count(out, "\n") < rows {
+		fmt.Print(out)
+		return
+	}
+
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less -R"
+	}
+
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdin = strings.NewReader(out)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// If the pager cannot be started, at least show the output.
+	if err := cmd.Run(); err != nil {
+		fmt.Print(out)
+	}
+}
+
+// isTerminal returns true if the file is a terminal
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// terminalSize returns the number of rows and columns of the terminal,
+// defaulting to 24x80 if they cannot be figured out
+func terminalSize() (rows, cols int) {
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	if out, err := cmd.Output(); err == nil {
+		fmt.Sscan(string(out), &rows, &cols)
+	}
+
+	if rows == 0 {
+		rows = 24
+	}
+	if cols == 0 {
+		cols = 80
+	}
+	return
+}
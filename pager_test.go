@@ -0,0 +1,13 @@
+package main
+
+import (
+	"strings"
+)
+
+// Output that is not going to a terminal is never paged, no matter how long.
+func Example_page() {
+	page(strings.Repeat("line\n", 3))
+	// Output: line
+	// line
+	// line
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/fatih/color"
+)
+
+// plain makes the output bare text for use in other tools: no colors, no
+// indexes and no wrapping. Listings have one item per line, with any fields
+// separated by tabs.
+var plain = false
+
+// setPlain turns on plain output
+func setPlain() {
+	plain = true
+	color.NoColor = true
+}
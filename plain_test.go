@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPlainTypesHaveNoDecoration(t *testing.T) {
+	assert := assert.New(t)
+	h := testHostInfo()
+
+	noColor := color.NoColor
+	defer func() {
+		plain = false
+		color.NoColor = noColor
+	}()
+	setPlain()
+
+	out := h.Types.plainTypes(h.Types.List(), PrintOptions{})
+
+	assert.NotContains(out, "\x1b[")
+	assert.NotContains(out, "[0]")
+	assert.Contains(out, "master\tdb1.cluster6.company.net\tprimary\t\n")
+	assert.Contains(out, "ro\tdb4.cluster3.company.net\tprimary\tDesignated for long queries\n")
+}
+
+func TestPlainDisablesColors(t *testing.T) {
+	assert := assert.New(t)
+
+	noColor := color.NoColor
+	defer func() {
+		plain = false
+		color.NoColor = noColor
+	}()
+	setPlain()
+
+	out := DiffStat{Added: 1}.String()
+
+	assert.NotContains(out, "\x1b[")
+	assert.Equal("1 added, 0 modified, 0 deleted", out)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressDelay is how long loading has to take before progress is shown
+const progressDelay = 500 * time.Millisecond
+
+// loading counts the files loaded by the repositories. It is nil unless
+// LoadRepos is showing progress.
+var loading *progress
+
+// progress shows a counter of loaded files while the repositories load
+//
+// Small trees load faster than anyone notices, so nothing is shown until the
+// delay has passed. The counter is updated by all of the concurrent loaders.
+type progress struct {
+	loaded int64
+	out    io.Writer
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// startProgress starts showing progress on out once delay has passed
+func startProgress(out io.Writer, delay time.Duration) *progress {
+	p := &progress{out: out, done: make(chan struct{})}
+
+	p.wg.Add(1)
+	go p.run(delay)
+
+	return p
+}
+
+// add counts one more loaded file. It is safe to call on a nil progress.
+func (p *progress) add() {
+	if p != nil {
+		atomic.AddInt64(&p.loaded, 1)
+	}
+}
+
+// count returns the number of files loaded so far
+func (p *progress) count() int64 {
+	return atomic.LoadInt64(&p.loaded)
+}
+
+// stop stops showing progress and clears the line if anything was shown
+func (p *progress) stop() {
+	close(p.done)
+	p.wg.Wait()
+}
+
+func (p *progress) run(delay time.Duration) {
+	defer p.wg.Done()
+
+	select {
+	case <-time.After(delay):
+	case <-p.done:
+		return
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		fmt.Fprintf(p.out, "\rLoading... %d files", p.count())
+
+		select {
+		case <-ticker.C:
+		case <-p.done:
+			// Carriage return and erase the line
+			fmt.Fprint(p.out, "\r\x1b[K")
+			return
+		}
+	}
+}
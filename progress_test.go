@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProgressCountsConcurrentAdds(t *testing.T) {
+	assert := assert.New(t)
+	var out bytes.Buffer
+	p := startProgress(&out, time.Hour)
+
+	var wg sync.WaitGroup
+	for x := 0; x < 50; x++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.add()
+		}()
+	}
+	wg.Wait()
+	p.stop()
+
+	assert.Equal(int64(50), p.count())
+}
+
+func TestProgressIsSilentForFastLoads(t *testing.T) {
+	assert := assert.New(t)
+	var out bytes.Buffer
+
+	p := startProgress(&out, time.Hour)
+	p.add()
+	p.stop()
+
+	assert.Equal("", out.String())
+}
+
+func TestProgressClearsTheLine(t *testing.T) {
+	assert := assert.New(t)
+	var out bytes.Buffer
+
+	p := startProgress(&out, 0)
+	time.Sleep(50 * time.Millisecond)
+	p.stop()
+
+	assert.Contains(out.String(), "Loading... 0 files")
+	assert.Contains(out.String(), "\r\x1b[K")
+}
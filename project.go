@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// markerFile is the name of the file that points out the repo of a project
+const markerFile = ".sagacity"
+
+// findProject looks for a .sagacity file in dir and every directory above it,
+// the same way that git looks for .git, and returns the repo it points at
+//
+// The file contains the path of the repo, relative to the directory the file
+// is in. If it is empty, that directory is the repo.
+func findProject(dir string) (string, bool) {
+	dir, _ = filepath.Abs(dir)
+
+	for {
+		fn := filepath.Join(dir, markerFile)
+		if fi, err := os.Stat(fn); err == nil && !fi.IsDir() {
+			data, err := ioutil.ReadFile(fn)
+			if err != nil {
+				return "", false
+			}
+
+			root := strings.TrimSpace(string(data))
+			if !filepath.IsAbs(root) {
+				root = filepath.Join(dir, root)
+			}
+			return filepath.Clean(root), true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// checkRoot makes sure that a repo given with --root can be loaded, and
+// returns its absolute path
+func checkRoot(dir string) (string, error) {
+	dir, _ = filepath.Abs(dir)
+
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("Cannot use %s as the root: %s", dir, err)
+	}
+	if !fi.IsDir() {
+		return "", fmt.Errorf("Cannot use %s as the root: not a directory", dir)
+	}
+	if _, err := ioutil.ReadDir(dir); err != nil {
+		return "", fmt.Errorf("Cannot use %s as the root: %s", dir, err)
+	}
+
+	return dir, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindProjectWalksUp(t *testing.T) {
+	assert := assert.New(t)
+	docs, _ := filepath.Abs("test/project/docs")
+
+	root, ok := findProject("test/project/src/deep/er")
+
+	assert.True(ok)
+	assert.Equal(docs, root)
+}
+
+func TestFindProjectWithoutMarker(t *testing.T) {
+	assert := assert.New(t)
+
+	_, ok := findProject("/")
+
+	assert.False(ok)
+}
+
+func TestRepoDirsOfProject(t *testing.T) {
+	assert := assert.New(t)
+	conf := &Config{Repositories: []string{"/srv/infra"}, Project: "/src/app/docs"}
+
+	assert.Equal([]string{"/src/app/docs"}, conf.RepoDirs())
+}
+
+func TestCheckRoot(t *testing.T) {
+	assert := assert.New(t)
+	docs, _ := filepath.Abs("test/project/docs")
+
+	dir, err := checkRoot("test/project/docs")
+	assert.Nil(err)
+	assert.Equal(docs, dir)
+
+	_, err = checkRoot("test/project/nope")
+	assert.Contains(err.Error(), "Cannot use")
+
+	_, err = checkRoot("test/project/.sagacity")
+	assert.Contains(err.Error(), "not a directory")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"github.com/fatih/color"
+	"strings"
+)
+
+// missing returns the things that the item should have but does not: a type,
+// a summary and a body (or a url for url items). Sections count as a body.
+func (i Info) missing() (m []string) {
+	if i.RawType == "" {
+		m = append(m, "type")
+	}
+	if i.RawSummary == "" {
+		m = append(m, "summary")
+	}
+
+	if i.RawType == "url" {
+		if i.URL == "" {
+			m = append(m, "url")
+		}
+	} else if strings.TrimSpace(i.Body) == "" && len(i.Sections) == 0 {
+		m = append(m, "body")
+	}
+
+	return
+}
+
+// Incomplete is an info item that is missing something
+type Incomplete struct {
+	Item    *Info
+	Missing []string
+}
+
+func (i Incomplete) String() string {
+	return fmt.Sprintf("%s: no %s", i.Item.Path(), strings.Join(i.Missing, ", "))
+}
+
+// Incomplete returns the info items of the repository that are missing
+// something, and those of the subrepos if recursive is set
+func (r *Repo) Incomplete(recursive bool) (found []Incomplete) {
+	for _, key := range r.Keys() {
+		item, _ := r.Item(key)
+		if info, ok := item.(*Info); ok {
+			if m := info.missing(); len(m) != 0 {
+				found = append(found, Incomplete{info, m})
+			}
+		}
+	}
+
+	if recursive {
+		for _, key := range r.SubrepoKeys() {
+			sub, _ := r.Subrepo(key)
+			found = append(found, sub.Incomplete(true)...)
+		}
+	}
+
+	return
+}
+
+// Prune reports the info items that are missing a type, a summary or a body,
+// so that they can be cleaned up. Nothing is changed.
+//
+// If a key is given, only that repo is checked. The process exits non-zero if
+// anything was found, so that it can be used in CI.
+func Prune(repos map[string]*Repo, key string, recursive bool) {
+	yellow := color.New(color.FgYellow).SprintfFunc()
+	green := color.New(color.FgGreen, color.Bold).SprintfFunc()
+
+	keys := repoKeys(repos, key)
+
+	count := 0
+	for _, k := range keys {
+		for _, inc := range repos[k].Incomplete(recursive) {
+			fmt.Println(yellow(inc.String()))
+			count++
+		}
+	}
+
+	if count != 0 {
+		fmt.Printf("%d incomplete items\n", count)
+		exit(exitError)
+	}
+	fmt.Println(green("All items are complete"))
+}
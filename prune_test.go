@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIncompleteTopLevel(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/prune/")
+
+	found := r.Incomplete(false)
+
+	assert.Equal(2, len(found))
+	assert.Equal("empty", found[0].Item.ID())
+	assert.Equal([]string{"summary", "body"}, found[0].Missing)
+	assert.Equal("notype", found[1].Item.ID())
+	assert.Equal([]string{"type"}, found[1].Missing)
+}
+
+func TestIncompleteRecursive(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/prune/")
+
+	found := r.Incomplete(true)
+
+	assert.Equal(3, len(found))
+	assert.Equal("wiki", found[2].Item.ID())
+	assert.Equal([]string{"url"}, found[2].Missing)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// RepoSet holds the loaded repositories for modes that keep running, and that
+// therefore need to pick up changes to the YAML files without a restart
+//
+// Reloading builds a completely new set of repositories and then swaps it in.
+// Anything that got the repositories from Get() before the swap keeps working
+// on the old ones, so commands in flight are never disrupted.
+type RepoSet struct {
+	mu    sync.RWMutex
+	conf  *Config
+	repos map[string]*Repo
+}
+
+// NewRepoSet holds repositories that are already loaded from the
+// configuration, and loads them from it again on Reload
+func NewRepoSet(conf *Config, repos map[string]*Repo) *RepoSet {
+	return &RepoSet{conf: conf, repos: repos}
+}
+
+// Get returns the currently loaded repositories
+func (s *RepoSet) Get() map[string]*Repo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.repos
+}
+
+// Reload loads the repositories again and swaps them in
+func (s *RepoSet) Reload() {
+	// Load outside of the lock so that readers are not blocked meanwhile
+	repos := LoadRepos(s.conf)
+
+	s.mu.Lock()
+	s.repos = repos
+	s.mu.Unlock()
+}
+
+// ReloadOnHangup reloads the repositories every time the process gets a
+// SIGHUP, until stop is closed
+func (s *RepoSet) ReloadOnHangup(stop <-chan struct{}) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(c)
+		for {
+			select {
+			case <-c:
+				log.Println("Got SIGHUP, reloading repositories")
+				s.Reload()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRepoSetReloadKeepsOldSnapshots(t *testing.T) {
+	assert := assert.New(t)
+	conf := &Config{Repositories: []string{"test/inherit"}}
+	s := NewRepoSet(conf, LoadRepos(conf))
+
+	before := s.Get()
+	conf.Repositories = append(conf.Repositories, "test/sshdefaults")
+	s.Reload()
+
+	assert.Equal(1, len(before))
+	assert.Equal(2, len(s.Get()))
+}
+
+func TestRepoSetReloadsOnHangup(t *testing.T) {
+	assert := assert.New(t)
+	conf := &Config{Repositories: []string{"test/inherit"}}
+	s := NewRepoSet(conf, LoadRepos(conf))
+
+	stop := make(chan struct{})
+	defer close(stop)
+	s.ReloadOnHangup(stop)
+
+	conf.Repositories = append(conf.Repositories, "test/sshdefaults")
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+
+	for x := 0; x < 100 && len(s.Get()) != 2; x++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Equal(2, len(s.Get()))
+}
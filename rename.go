@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RenameItem gives an info item a new key, by renaming its file, and updates
+// the links and `related` entries that point at it
+func RenameItem(repos map[string]*Repo, path, key string) {
+	info := findInfo(repos, path)
+	if err := checkKey(key); err != nil {
+		fmt.Println(err)
+		exit(exitError)
+	}
+
+	changes, err := relocate(info, info.repo, key)
+	if err != nil {
+		fmt.Println(err)
+		exit(exitError)
+	}
+
+	fmt.Printf("Renamed %s to %s\n", info.ID(), key)
+	printChanges(changes)
+}
+
+// findInfo returns the info item at a path like infra/runbooks/deploy, exiting
+// if there is none
+func findInfo(repos map[string]*Repo, path string) *Info {
+	item, err := findItem(repos, strings.Split(path, "/"))
+	if err != nil {
+		fmt.Printf("%s: %s\n", path, err)
+		exit(exitNotFound)
+	}
+
+	info, ok := item.(*Info)
+	if !ok {
+		fmt.Printf("%s: only info items can be renamed or moved, not %s items\n", path, item.Type())
+		exit(exitError)
+	}
+	return info
+}
+
+// checkKey returns an error if a key cannot be used for an item
+func checkKey(key string) error {
+	if key == "" || strings.ContainsAny(key, "/\\") || strings.HasPrefix(key, ".") || strings.HasPrefix(key, "_") {
+		return fmt.Errorf("%q cannot be the key of an item", key)
+	}
+	return nil
+}
+
+// printChanges prints the references that were updated
+func printChanges(changes []string) {
+	if len(changes) == 0 {
+		fmt.Println("No references to update")
+		return
+	}
+	for _, change := range changes {
+		fmt.Println(" ", change)
+	}
+}
+
+// relocate moves the file of an info item into the directory of a repo under
+// a new key, and rewrites the references to it in every item of its root repo
+// so that they point at the new place. The links and `related` entries of the
+// item itself are rewritten too, so that they still point at the same items
+// from there. It returns a description of each reference that was changed.
+//
+// Nothing is changed if the key is already taken in the repo, and the file is
+// moved before any references are rewritten, so that they never point at an
+// item that is not there.
+func relocate(info *Info, to *Repo, key string) ([]string, error) {
+	if _, ok := to.Item(key); ok {
+		return nil, fmt.Errorf("There already is an item called %s in %s", key, to.root)
+	}
+	if _, ok := to.Subrepo(key); ok {
+		return nil, fmt.Errorf("There already is a subrepo called %s in %s", key, to.root)
+	}
+
+	dest := filepath.Join(to.root, key+filepath.Ext(info.Path()))
+	if _, err := os.Stat(dest); err == nil {
+		return nil, fmt.Errorf("%s already exists", dest)
+	}
+
+	// Find everything that points at the item before it goes away
+	rewrites := make(map[*Info]map[string]string)
+	for _, other := range info.repo.ParentRepo().Infos() {
+		if other.Path() == info.Path() {
+			continue
+		}
+		for _, ref := range append(other.Links(), other.Related()...) {
+			found, ok := other.repo.findLink(ref)
+			if !ok || found.Path() != info.Path() {
+				continue
+			}
+
+			if link := linkTo(other.repo, to, key); link != ref {
+				if rewrites[other] == nil {
+					rewrites[other] = make(map[string]string)
+				}
+				rewrites[other][ref] = link
+			}
+		}
+	}
+	own := info.ownRewrites(to, key)
+
+	if err := os.Rename(info.Path(), dest); err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	for other, refs := range rewrites {
+		if err := rewriteFile(other.Path(), other.repo, refs); err != nil {
+			return changes, err
+		}
+		for ref, link := range refs {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", other.Path(), ref, link))
+		}
+	}
+	if len(own) != 0 {
+		if err := rewriteFile(dest, to, own); err != nil {
+			return changes, err
+		}
+		for ref, link := range own {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", dest, ref, link))
+		}
+	}
+	sort.Strings(changes)
+
+	return changes, nil
+}
+
+// ownRewrites returns the links and `related` entries of the item that have to
+// change for them to point at the same items once it is key in the repo to
+func (i Info) ownRewrites(to *Repo, key string) map[string]string {
+	refs := make(map[string]string)
+	for _, ref := range append(i.Links(), i.Related()...) {
+		found, repo, ok := i.repo.resolveLink(ref)
+		if !ok {
+			continue
+		}
+
+		// Links to the item itself only need the new key
+		link := key
+		if found.Path() != i.Path() {
+			if again, ok := to.findLink(ref); ok && again.Path() == found.Path() {
+				continue
+			}
+			link = linkTo(to, repo, found.ID())
+		}
+
+		if link != ref {
+			refs[ref] = link
+		}
+	}
+	return refs
+}
+
+// linkTo returns the link that an item in the repo from uses for the item
+// with a key in the repo to, which is the path of keys to it from the closest
+// parent of from that it is in
+func linkTo(from, to *Repo, key string) string {
+	for base := from; base != nil; base = base.Parent {
+		keys := []string{key}
+		for r := to; r != nil; r = r.Parent {
+			if r == base {
+				return strings.Join(keys, "/")
+			}
+			keys = append([]string{r.Key}, keys...)
+		}
+	}
+	return key
+}
+
+// rewriteFile replaces references in the file of an item, and runs the
+// formatter of the repo it is in on it
+func rewriteFile(fn string, repo *Repo, refs map[string]string) error {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+
+	s := string(data)
+	for ref, link := range refs {
+		s = rewriteRefs(s, ref, link)
+	}
+
+	if err := ioutil.WriteFile(fn, []byte(s), fi.Mode()); err != nil {
+		return err
+	}
+	_, err = repo.formatFile(fn)
+	return err
+}
+
+// rewriteRefs replaces the [[links]] and `related` entries that say ref in the
+// text of a file with link
+//
+// The file is changed as text rather than loaded and saved again, so that
+// everything else in it stays exactly the way it was written.
+func rewriteRefs(s, ref, link string) string {
+	s = strings.Replace(s, "[["+ref+"]]", "[["+link+"]]", -1)
+
+	lines := strings.Split(s, "\n")
+	inList := false
+	for x, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "related:"):
+			value := strings.TrimSpace(strings.TrimPrefix(line, "related:"))
+			inList = value == ""
+			if v := rewriteInline(value, ref, link); v != value {
+				lines[x] = "related: " + v
+			}
+
+		case inList && strings.HasPrefix(trimmed, "-"):
+			if unquote(strings.TrimSpace(trimmed[1:])) == ref {
+				indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+				lines[x] = indent + "- " + link
+			}
+
+		case trimmed != "" && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t"):
+			inList = false
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// rewriteInline replaces ref in a `related` value that is on the same line,
+// which is either a single key or a list like [a, b]
+func rewriteInline(value, ref, link string) string {
+	if unquote(value) == ref {
+		return link
+	}
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return value
+	}
+
+	parts := strings.Split(value[1:len(value)-1], ",")
+	for x, part := range parts {
+		if p := strings.TrimSpace(part); unquote(p) == ref {
+			parts[x] = strings.Replace(part, p, link, 1)
+		}
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// unquote removes the quotes around a YAML string, if it has any
+func unquote(s string) string {
+	return strings.Trim(s, `"'`)
+}
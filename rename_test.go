@@ -0,0 +1,119 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeRepo writes the files of a repo to a temporary directory, returning
+// the directory and a function that removes it
+func writeRepo(files map[string]string) (string, func()) {
+	dir, _ := ioutil.TempDir("", "sagacity")
+	for fn, content := range files {
+		path := filepath.Join(dir, fn)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(content), 0644)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// renameFiles is a repo where two files refer to dns/zones
+var renameFiles = map[string]string{
+	"_repo.yaml":       "",
+	"failover.yaml":    "type: info\nrelated: [dns/zones, other]\nbody: See [[dns/zones]] first.\n",
+	"dns/_repo.yaml":   "",
+	"dns/zones.yaml":   "type: info\nbody: Back to [[failover]].\n",
+	"dns/records.yaml": "type: info\nrelated:\n  - zones\n",
+}
+
+func TestRelocateRewritesReferences(t *testing.T) {
+	assert := assert.New(t)
+	dir, done := writeRepo(renameFiles)
+	defer done()
+
+	r := NewRepo(dir)
+	dns := r.Subrepos["dns"]
+	changes, err := relocate(dns.Items["zones"].(*Info), dns, "domains")
+
+	assert.Nil(err)
+	assert.Equal(2, len(changes))
+
+	failover, _ := ioutil.ReadFile(filepath.Join(dir, "failover.yaml"))
+	assert.Equal("type: info\nrelated: [dns/domains, other]\nbody: See [[dns/domains]] first.\n", string(failover))
+	records, _ := ioutil.ReadFile(filepath.Join(dir, "dns/records.yaml"))
+	assert.Equal("type: info\nrelated:\n  - domains\n", string(records))
+
+	// The renamed item is found under its new key, and still links back
+	r = NewRepo(dir)
+	item, ok := r.findLink("dns/domains")
+	assert.True(ok)
+	assert.Equal("Back to [[failover]].", item.(*Info).Body)
+	assert.Equal(0, len(r.BrokenLinks()))
+}
+
+func TestRelocateRefusesTakenKeys(t *testing.T) {
+	assert := assert.New(t)
+	dir, done := writeRepo(renameFiles)
+	defer done()
+
+	r := NewRepo(dir)
+	dns := r.Subrepos["dns"]
+	_, err := relocate(dns.Items["zones"].(*Info), dns, "records")
+
+	assert.Contains(err.Error(), "There already is an item called records")
+	_, err = os.Stat(filepath.Join(dir, "dns/zones.yaml"))
+	assert.Nil(err)
+}
+
+func TestRelocateToAnotherSubrepo(t *testing.T) {
+	assert := assert.New(t)
+	dir, done := writeRepo(renameFiles)
+	defer done()
+
+	r := NewRepo(dir)
+	changes, err := relocate(r.Subrepos["dns"].Items["zones"].(*Info), r, "zones")
+
+	assert.Nil(err)
+	assert.Equal([]string{filepath.Join(dir, "failover.yaml") + ": dns/zones -> zones"}, changes)
+
+	// dns/records still says zones, which is now found in the parent
+	r = NewRepo(dir)
+	item, ok := r.Subrepos["dns"].findLink("zones")
+	assert.True(ok)
+	assert.Equal(filepath.Join(dir, "zones.yaml"), item.Path())
+	failover, _ := ioutil.ReadFile(filepath.Join(dir, "failover.yaml"))
+	assert.Equal("type: info\nrelated: [zones, other]\nbody: See [[zones]] first.\n", string(failover))
+}
+
+func TestRelocateRewritesItsOwnLinks(t *testing.T) {
+	assert := assert.New(t)
+	dir, done := writeRepo(renameFiles)
+	defer done()
+
+	// records says zones, which is only found from inside of dns
+	r := NewRepo(dir)
+	changes, err := relocate(r.Subrepos["dns"].Items["records"].(*Info), r, "records")
+
+	assert.Nil(err)
+	assert.Equal([]string{filepath.Join(dir, "records.yaml") + ": zones -> dns/zones"}, changes)
+	records, _ := ioutil.ReadFile(filepath.Join(dir, "records.yaml"))
+	assert.Equal("type: info\nrelated:\n  - dns/zones\n", string(records))
+	assert.Equal(0, len(NewRepo(dir).BrokenLinks()))
+}
+
+func TestRelocateChangesNothingIfTheMoveFails(t *testing.T) {
+	assert := assert.New(t)
+	dir, done := writeRepo(renameFiles)
+	defer done()
+
+	r := NewRepo(dir)
+	gone := &Repo{Key: "gone", root: filepath.Join(dir, "gone"), Parent: r}
+	_, err := relocate(r.Subrepos["dns"].Items["zones"].(*Info), gone, "zones")
+
+	assert.NotNil(err)
+	failover, _ := ioutil.ReadFile(filepath.Join(dir, "failover.yaml"))
+	assert.Equal(renameFiles["failover.yaml"], string(failover))
+}
@@ -1,32 +1,118 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/codegangsta/cli"
+	"github.com/fatih/color"
 	"gopkg.in/yaml.v2"
-	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Repo represents a repository of information yaml files.
+//
+// The subrepos and items of a repository are added while it loads. Keys(),
+// SortedKeys(), SubrepoKeys(), Item(), Subrepo() and everything built on them
+// (HostInfos(), GetItem(), GetSubrepo() and Execute()) can be called at any
+// time, even while loading. Reading the maps directly is only safe once the
+// repository has finished loading.
 type Repo struct {
-	Key      string `yaml:"key"`
-	Summary  string `yaml:"summary"`
-	Alias    string `yaml:"alias"`
+	Key      string   `yaml:"key"`
+	Summary  string   `yaml:"summary"`
+	Alias    string   `yaml:"alias"`
+	Icon     string   `yaml:"icon"`
+	PostLoad string   `yaml:"post_load"`
+	Settings Settings `yaml:",inline"`
 	Items    map[string]Item
 	Control  map[string]Item
 	Subrepos map[string]*Repo
 	Parent   *Repo
 	root     string
+	errs     []error
+	failed   map[string]error
+	mu       sync.RWMutex
+}
+
+// Settings are the parts of a _repo.yaml that are inherited by subrepos.
+//
+// A subrepo starts out with the settings of its parent, and anything set in
+// its own _repo.yaml overrides them. Since the parent has already done the
+// same with its own parent, the closest definition always wins. Root repos
+// start out with the ssh_defaults of the configuration:
+//
+//	subrepo _repo.yaml > parent _repo.yaml > ... > root _repo.yaml > sagacity.yaml
+type Settings struct {
+	Color          string     `yaml:"color"`
+	Ignore         []string   `yaml:"ignore"`
+	SSHDefaults    SSHOptions `yaml:"ssh_defaults"`
+	FollowSymlinks *bool      `yaml:"follow_symlinks"`
+	Remote         string     `yaml:"remote"`
+	Formatter      string     `yaml:"formatter"`
+	Footer         string     `yaml:"footer"`
+}
+
+// repoColors are the colors that repos can have in listings
+var repoColors = map[string]color.Attribute{
+	"black":   color.FgBlack,
+	"red":     color.FgRed,
+	"green":   color.FgGreen,
+	"yellow":  color.FgYellow,
+	"blue":    color.FgBlue,
+	"magenta": color.FgMagenta,
+	"cyan":    color.FgCyan,
+	"white":   color.FgWhite,
+}
+
+// inherit fills in anything that is not set in s from the parent settings
+func (s Settings) inherit(parent Settings) Settings {
+	if s.Color == "" {
+		s.Color = parent.Color
+	}
+	if s.Ignore == nil {
+		s.Ignore = parent.Ignore
+	}
+	s.SSHDefaults = s.SSHDefaults.merge(parent.SSHDefaults)
+	if s.FollowSymlinks == nil {
+		s.FollowSymlinks = parent.FollowSymlinks
+	}
+	if s.Remote == "" {
+		s.Remote = parent.Remote
+	}
+	if s.Formatter == "" {
+		s.Formatter = parent.Formatter
+	}
+	if s.Footer == "" {
+		s.Footer = parent.Footer
+	}
+
+	return s
+}
+
+// followsSymlinks returns true if symlinked directories should be loaded.
+// They are not unless a _repo.yaml explicitly asks for it.
+func (s Settings) followsSymlinks() bool {
+	return s.FollowSymlinks != nil && *s.FollowSymlinks
+}
+
+// ignored returns true if a file name matches any of the ignore patterns
+func (s Settings) ignored(name string) bool {
+	for _, pattern := range s.Ignore {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
 }
 
-func (r Repo) String() string {
+func (r *Repo) String() string {
 	return fmt.Sprintf("R: %s (%d articles)", r.Key, len(r.Items))
 }
 
@@ -35,16 +121,28 @@ func LoadRepos(c *Config) (repos map[string]*Repo) {
 	repos = make(map[string]*Repo)
 	cr := make(chan *Repo)
 
+	// Only show progress to people who are looking at it
+	if !c.Quiet && isTerminal(os.Stderr) {
+		loading = startProgress(os.Stderr, progressDelay)
+		defer func() {
+			loading.stop()
+			loading = nil
+		}()
+	}
+
+	base := c.settings()
 	started := 0
-	for _, file := range c.Repositories {
-		if _, err := os.Stat(filepath.Join(file, "_repo.yaml")); os.IsNotExist(err) {
-			// log.Println(fmt.Sprintf("Skipping repo %s: no _repo.yaml found.", file.Name()))
+	for _, file := range c.RepoDirs() {
+		if _, err := repoFS.Stat(filepath.Join(file, "_repo.yaml")); os.IsNotExist(err) {
+			if !c.Quiet {
+				log.Println(fmt.Sprintf("Skipping repo %s: no _repo.yaml found.", file))
+			}
 			continue
 		}
 
 		started++
 		go func(c chan<- *Repo, fn string) {
-			c <- NewRepo(fn)
+			c <- newRepo(fn, nil, base, nil)
 		}(cr, file)
 	}
 
@@ -55,63 +153,221 @@ func LoadRepos(c *Config) (repos map[string]*Repo) {
 		}
 	}
 
+	if c.Hooks {
+		for _, r := range repos {
+			for _, err := range r.runHooks() {
+				log.Print(err)
+			}
+		}
+	}
+
 	return
 }
 
 // UpdateRepos will run git pull on the repos
-func UpdateRepos(repos map[string]*Repo) {
-	for key, repo := range repos {
-		log.Printf("Updating %s...", key)
-		repo.git("pull", "origin", "master")
+//
+// If all is set, any subrepos that are git repositories of their own are
+// pulled as well. Pulls that fail because of the network are tried again up to
+// retries times. Repos that could not be updated are reported, and the process
+// exits non-zero after all of them have been tried.
+//
+// Repos are pulled from the remote in their _repo.yaml, or from remote if they
+// do not set one.
+func UpdateRepos(repos map[string]*Repo, all bool, retries int, remote string) {
+	red := color.New(color.FgRed, color.Bold).SprintfFunc()
+
+	failed := 0
+	for _, repo := range repos {
+		targets := []*Repo{repo}
+		if all {
+			targets = append(targets, repo.gitSubrepos()...)
+		}
+
+		for _, r := range targets {
+			log.Printf("Updating %s...", r.root)
+
+			stat, err := r.update(retries, remote)
+			if err != nil {
+				fmt.Printf("%s: %s\n", r.root, red("update failed: %s", err))
+				failed++
+				continue
+			}
+
+			fmt.Printf("%s: %s\n", r.root, stat)
+		}
+	}
+
+	if failed != 0 {
+		exit(exitGitError)
 	}
 }
 
+// update pulls the repo and returns what the pull changed
+//
+// It fails if the changes cannot be found out, rather than saying that the
+// repo is up to date.
+func (r *Repo) update(retries int, remote string) (DiffStat, error) {
+	before, err := gitOutput(r.root, "rev-parse", "HEAD")
+	if err != nil {
+		return DiffStat{}, err
+	}
+	if err := gitRetry(r.root, retries, r.pullArgs(remote)...); err != nil {
+		return DiffStat{}, err
+	}
+	diff, err := gitOutput(r.root, "diff", "--name-status", before, "HEAD")
+	if err != nil {
+		return DiffStat{}, err
+	}
+
+	return parseNameStatus(diff), nil
+}
+
+// pullArgs returns the arguments of the git pull that updates the repo
+func (r *Repo) pullArgs(remote string) []string {
+	if r.Settings.Remote != "" {
+		remote = r.Settings.Remote
+	}
+	return []string{"pull", "--quiet", remote, "master"}
+}
+
+// DiffStat is the number of files changed by an update
+type DiffStat struct {
+	Added    int
+	Modified int
+	Deleted  int
+}
+
+func (d DiffStat) String() string {
+	if d == (DiffStat{}) {
+		return "up to date"
+	}
+
+	green := color.New(color.FgGreen, color.Bold).SprintfFunc()
+	yellow := color.New(color.FgYellow, color.Bold).SprintfFunc()
+	red := color.New(color.FgRed, color.Bold).SprintfFunc()
+
+	return fmt.Sprintf(
+		"%s added, %s modified, %s deleted",
+		green("%d", d.Added),
+		yellow("%d", d.Modified),
+		red("%d", d.Deleted),
+	)
+}
+
+// parseNameStatus counts the changes in the output of `git diff --name-status`
+//
+// Renames and copies count as modifications, since the content is still there.
+func parseNameStatus(out string) (d DiffStat) {
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+
+		switch line[0] {
+		case 'A':
+			d.Added++
+		case 'D':
+			d.Deleted++
+		default:
+			d.Modified++
+		}
+	}
+
+	return
+}
+
 // AddRepo clones a new repository
 func AddRepo(config *Config, url string) {
-	// Clean the name of prefixes and stuff, leaving just the trailing word. This
-	// lets us use `saga-topic` or `kb-topic` or whatever and we'll still get
-	// just `topic` when we're grabbing.
-	rxp := regexp.MustCompile(".*-")
-	name := rxp.ReplaceAllString(url, "")
+	name := repoName(url)
 
 	// Clone the repo! |o/
 	dir := filepath.Join(config.RepoRoot, name)
-	git("", "clone", url, dir)
+	git("", "clone", "--origin", config.remote(), url, dir)
 
 	// Persist the changes into the configuration file
 	err := config.AddRepo(dir)
 	if err != nil {
-		log.Fatal(err)
+		fmt.Println(err)
+		exit(exitError)
 	}
 
 	log.Printf("Added %s as %s!\n", url, name)
 }
 
+// repoName makes the name of a repository directory from its URL
+//
+// The name is cleaned of prefixes and stuff, leaving just the trailing word.
+// This lets us use `saga-topic` or `kb-topic` or whatever and we'll still get
+// just `topic` when we're grabbing.
+func repoName(url string) string {
+	rxp := regexp.MustCompile(".*-")
+	return rxp.ReplaceAllString(url, "")
+}
+
 // NewRepo loads a repository on a path
 func NewRepo(p string) *Repo {
+	return newRepo(p, nil, Settings{}, nil)
+}
+
+// NewRepoStream loads a repository on a path and sends every item on the
+// returned channel as soon as it has been parsed, so that commands can start
+// showing results before the whole repository has loaded
+//
+// Items of subrepos are sent too, but not control files. The channel is
+// closed once everything has loaded, and has to be drained until then.
+func NewRepoStream(p string) <-chan Item {
+	out := make(chan Item)
+	go func() {
+		newRepo(p, nil, Settings{}, out)
+		close(out)
+	}()
+	return out
+}
+
+// newRepo loads a repository on a path as a subrepo of parent
+//
+// The parent is nil for root repositories. Anything that the _repo.yaml does
+// not set is inherited from base, which is the settings of the parent for
+// subrepos and those of the configuration for root repos. If out is not nil,
+// every item is sent on it once it has been added to its repository.
+func newRepo(p string, parent *Repo, base Settings, out chan<- Item) *Repo {
 	var subdirs []string
-	var items []string
+	var items []itemFile
 
 	p = getPath(p)
-	r := Repo{Key: asKey(p), root: p}
+	r := Repo{Key: asKey(p), root: p, Parent: parent}
 
 	// Check if this is a root repo. If it is, load the data from the _repo.yaml file into
 	// the newly created repo.
 	rfile := filepath.Join(p, "_repo.yaml")
-	if _, err := os.Stat(rfile); !os.IsNotExist(err) {
-		data, err := ioutil.ReadFile(rfile)
-
+	if _, err := repoFS.Stat(rfile); !os.IsNotExist(err) {
+		data, err := repoFS.ReadFile(rfile)
 		if err != nil {
-			log.Fatal("Reading repo file failed: ", p)
+			fmt.Println("Reading repo file failed:", err)
+			exit(exitError)
+		}
+		if err := yaml.Unmarshal(data, &r); err != nil {
+			log.Printf("Could not read %s: %s", rfile, err)
+			r.fail(fmt.Errorf("%s: %s", rfile, err))
+		}
+
+		if _, ok := repoColors[r.Settings.Color]; r.Settings.Color != "" && !ok {
+			log.Printf("Unknown color %q in %s, using the default", r.Settings.Color, rfile)
+			r.Settings.Color = ""
 		}
-		yaml.Unmarshal(data, &r)
 	}
 
+	r.Settings = r.Settings.inherit(base)
+
 	r.Items = make(map[string]Item)
 	r.Control = make(map[string]Item)
 	r.Subrepos = make(map[string]*Repo)
+	r.failed = make(map[string]error)
 
-	files, _ := ioutil.ReadDir(p)
+	files, err := repoFS.ReadDir(p)
+	if err != nil {
+		r.fail(err)
+	}
 
 	// Loop through the files and put files and dirs in different lists
 	for _, f := range files {
@@ -121,10 +377,28 @@ func NewRepo(p string) *Repo {
 			continue
 		}
 
-		if f.IsDir() {
+		// Explicitly ignored by the repo settings. Also skip.
+		if r.Settings.ignored(f.Name()) {
+			continue
+		}
+
+		if f.Mode()&os.ModeSymlink != 0 && isDir(fn) {
+			if r.follows(fn) {
+				subdirs = append(subdirs, fn)
+			}
+		} else if f.IsDir() {
 			subdirs = append(subdirs, fn)
-		} else if strings.HasSuffix(fn, ".yaml") {
-			items = append(items, fn)
+		} else if strings.HasSuffix(fn, ".yaml") || strings.HasSuffix(fn, ".md") {
+			// The directory listing already has the modification time, except
+			// for symlinks, where it is that of the link
+			mtime := f.ModTime()
+			if f.Mode()&os.ModeSymlink != 0 {
+				mtime = time.Time{}
+				if fi, err := repoFS.Stat(fn); err == nil {
+					mtime = fi.ModTime()
+				}
+			}
+			items = append(items, itemFile{fn, mtime})
 		}
 	}
 
@@ -134,57 +408,258 @@ func NewRepo(p string) *Repo {
 	// Start parsing subrepos
 	for _, dir := range subdirs {
 		go func(cs chan<- *Repo, dir string) {
-			nr := NewRepo(dir)
+			nr := newRepo(dir, &r, r.Settings, out)
 			cs <- nr
 		}(cs, dir)
 	}
 
 	// Start parsing items
-	for _, fn := range items {
-		go func(ci chan<- Item, fn string) {
-			ni := r.loadItem(fn)
+	for _, f := range items {
+		go func(ci chan<- Item, f itemFile) {
+			ni := r.loadItem(f.path, f.mtime)
 			ci <- ni
-		}(ci, fn)
+		}(ci, f)
 	}
 
 	// Drain the items first
 	for x := 0; x < len(items); x++ {
 		item := <-ci
-		// Control files start with an underscore and should not be stored as
-		// normal Item documents.
-		path := item.Path()
-		id := item.ID()
-		if strings.HasPrefix(asKey(path), "_") {
-			r.Control[id] = item
-		} else {
-			r.Items[id] = item
+		if item == nil {
+			continue
+		}
+		r.addItem(item)
+		if out != nil && !isControl(item) {
+			out <- item
 		}
 	}
 
 	// And then drain the subrepos
 	for x := 0; x < len(subdirs); x++ {
 		sub := <-cs
-		r.Subrepos[sub.Key] = sub
+		r.addSubrepo(sub)
 	}
 
 	return &r
 }
 
+// addItem stores an item
+//
+// Control files start with an underscore and should not be stored as normal
+// Item documents.
+//
+// Keys are file names without extensions, so `foo.yaml` and `foo.md` both end
+// up as `foo`. Like for subrepos, the one whose path sorts first is kept, and
+// the repository is marked as not loading completely.
+func (r *Repo) addItem(item Item) {
+	r.mu.Lock()
+	items := r.Items
+	if isControl(item) {
+		items = r.Control
+	}
+
+	prev, ok := items[item.ID()]
+	kept, dropped := item, prev
+	if ok && prev.Path() < item.Path() {
+		kept, dropped = prev, item
+	}
+	items[item.ID()] = kept
+	r.mu.Unlock()
+
+	if ok {
+		err := fmt.Errorf(
+			"%s and %s both have the key %q - ignoring %s",
+			kept.Path(), dropped.Path(), item.ID(), dropped.Path(),
+		)
+		log.Print(err)
+		r.fail(err)
+	}
+}
+
+// fail records why the repository did not load completely
+func (r *Repo) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.errs = append(r.errs, err)
+}
+
+// Err returns why the repository did not load completely, or nil if it did
+//
+// A repository whose subrepos did not load completely is not complete either,
+// so that a broken branch can be followed down from the top.
+func (r *Repo) Err() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.errs) == 1 {
+		return r.errs[0]
+	}
+	if len(r.errs) > 1 {
+		return fmt.Errorf("%s (and %d more)", r.errs[0], len(r.errs)-1)
+	}
+
+	keys := make([]string, 0, len(r.failed))
+	for key := range r.failed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) != 0 {
+		return fmt.Errorf("subrepo %s: %s", keys[0], r.failed[keys[0]])
+	}
+
+	return nil
+}
+
+// Failures returns the subrepos that did not load completely and why, by key
+func (r *Repo) Failures() map[string]error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	failed := make(map[string]error, len(r.failed))
+	for key, err := range r.failed {
+		failed[key] = err
+	}
+	return failed
+}
+
+// itemFile is a file that an item is loaded from
+type itemFile struct {
+	path  string
+	mtime time.Time
+}
+
+// isControl returns true if the item is a control file like _repo.yaml
+func isControl(item Item) bool {
+	return strings.HasPrefix(asKey(item.Path()), "_")
+}
+
+// addSubrepo stores a subrepo, warning if another one already has its key
+//
+// Keys are made from directory names without extensions (or set explicitly in
+// _repo.yaml), so two directories can end up with the same one. Since the
+// subrepos are loaded concurrently, the one whose path sorts first is kept so
+// that the result is the same on every run.
+func (r *Repo) addSubrepo(sub *Repo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := sub.Err(); err != nil {
+		r.failed[sub.Key] = err
+	}
+
+	prev, ok := r.Subrepos[sub.Key]
+	if !ok {
+		r.Subrepos[sub.Key] = sub
+		return
+	}
+
+	kept, dropped := prev, sub
+	if sub.root < prev.root {
+		kept, dropped = sub, prev
+	}
+
+	log.Printf(
+		"Subrepos %s and %s both have the key %q - ignoring %s",
+		kept.root, dropped.root, sub.Key, dropped.root,
+	)
+	r.Subrepos[sub.Key] = kept
+}
+
 // ListRepos prints a sorted list of available repostiories.
-func ListRepos(repos map[string]Repo) {
+//
+// The keys are shown with the color and icon of each repo, like subrepos in
+// listings, and the summary from the _repo.yaml is printed next to them. The
+// icons get a column of their own, so that the keys and the summaries line up
+// whether a repo has an icon or not. keysOnly prints just the keys, without
+// colors, for scripting. Plain output separates the keys and summaries by a
+// tab.
+func ListRepos(repos map[string]*Repo, keysOnly bool) {
+	grey := color.New(color.FgWhite).SprintfFunc()
+
 	keys := make([]string, 0, len(repos))
-	for key := range repos {
+	width, icons := 0, 0
+	for key, r := range repos {
 		keys = append(keys, key)
+		if len(key) > width {
+			width = len(key)
+		}
+		if r.iconWidth() > icons {
+			icons = r.iconWidth()
+		}
 	}
 
+	cols := outputCols()
 	sort.Strings(keys)
 	for _, key := range keys {
-		fmt.Println(key)
+		r := repos[key]
+		// Pad after the icon, so that the keys start in the same column
+		icon := ""
+		if r.Icon != "" {
+			icon = r.Icon + " "
+		}
+		icon += strings.Repeat(" ", icons-r.iconWidth())
+
+		summary := r.Summary
+		switch {
+		case keysOnly:
+			fmt.Println(key)
+		case plain:
+			if summary == "" {
+				fmt.Println(key)
+			} else {
+				fmt.Printf("%s\t%s\n", key, summary)
+			}
+		case summary == "":
+			fmt.Println(icon + r.colored("%s", key))
+		default:
+			if cols != 0 {
+				summary = truncate(summary, cols-icons-width-2)
+			}
+			// Pad before coloring, since the color codes would count as width
+			fmt.Printf("%s%s  %s\n", icon, r.colored("%-*s", width, key), grey(summary))
+		}
+	}
+}
+
+// label returns the key of the repository in its color, after its icon
+//
+// Repos without a color of their own are blue, like they have always been.
+// Plain output has neither.
+func (r *Repo) label(format string, a ...interface{}) string {
+	s := r.colored(format, a...)
+	if r.Icon != "" && !plain {
+		s = r.Icon + " " + s
 	}
+	return s
+}
+
+// colored is label without the icon
+func (r *Repo) colored(format string, a ...interface{}) string {
+	if plain {
+		return fmt.Sprintf(format, a...)
+	}
+
+	attr, ok := repoColors[r.Settings.Color]
+	if !ok {
+		attr = color.FgBlue
+	}
+	return color.New(attr, color.Bold).SprintfFunc()(format, a...)
+}
+
+// iconWidth is how many columns the icon takes up in a label, along with the
+// space after it
+func (r *Repo) iconWidth() int {
+	if r.Icon == "" {
+		return 0
+	}
+	return displayWidth(r.Icon) + 1
 }
 
 // Keys returns a sorted list of the info keys in the repository
 func (r *Repo) Keys() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	keys := make([]string, 0, len(r.Items))
 	for _, item := range r.Items {
 		keys = append(keys, item.ID())
@@ -195,8 +670,81 @@ func (r *Repo) Keys() []string {
 	return keys
 }
 
+// SortedKeys returns the info keys in the repository sorted by a mode
+//
+// The modes are "name", which is the same as Keys(), "order", which puts items
+// with an `order:` first in that order, and the rest after them by name, and
+// "mtime", which puts the most recently changed items first.
+func (r *Repo) SortedKeys(mode string) ([]string, error) {
+	keys := r.Keys()
+
+	switch mode {
+	case "", "name":
+	case "order":
+		r.mu.RLock()
+		sort.Stable(byOrder{keys, r.Items})
+		r.mu.RUnlock()
+	case "mtime":
+		r.mu.RLock()
+		sort.Stable(byModTime{keys, r.Items})
+		r.mu.RUnlock()
+	default:
+		return nil, fmt.Errorf("No such sort mode: %s. Choices are: name, order, mtime", mode)
+	}
+
+	return keys, nil
+}
+
+// orderer is implemented by items that can have a position in listings
+type orderer interface {
+	Order() (int, bool)
+}
+
+// byOrder sorts keys that are already sorted by name by the order of their
+// items. Since the sort is stable, ties stay sorted by name.
+type byOrder struct {
+	keys  []string
+	items map[string]Item
+}
+
+func (s byOrder) Len() int      { return len(s.keys) }
+func (s byOrder) Swap(i, j int) { s.keys[i], s.keys[j] = s.keys[j], s.keys[i] }
+func (s byOrder) Less(i, j int) bool {
+	a, aok := order(s.items[s.keys[i]])
+	b, bok := order(s.items[s.keys[j]])
+
+	if aok && bok {
+		return a < b
+	}
+	return aok && !bok
+}
+
+// byModTime sorts keys that are already sorted by name by the modification
+// time of their items, newest first
+type byModTime struct {
+	keys  []string
+	items map[string]Item
+}
+
+func (s byModTime) Len() int      { return len(s.keys) }
+func (s byModTime) Swap(i, j int) { s.keys[i], s.keys[j] = s.keys[j], s.keys[i] }
+func (s byModTime) Less(i, j int) bool {
+	return s.items[s.keys[i]].ModTime().After(s.items[s.keys[j]].ModTime())
+}
+
+// order returns the order of an item, if it has one
+func order(item Item) (int, bool) {
+	if o, ok := item.(orderer); ok {
+		return o.Order()
+	}
+	return 0, false
+}
+
 // SubrepoKeys returns a sorted list of the subrepo keys in the repository
 func (r *Repo) SubrepoKeys() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	keys := make([]string, 0, len(r.Subrepos))
 	for _, sub := range r.Subrepos {
 		keys = append(keys, sub.Key)
@@ -207,6 +755,58 @@ func (r *Repo) SubrepoKeys() []string {
 	return keys
 }
 
+// Item returns the item with a key
+func (r *Repo) Item(key string) (Item, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	item, ok := r.Items[key]
+	return item, ok
+}
+
+// Subrepo returns the subrepo with a key
+func (r *Repo) Subrepo(key string) (*Repo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sub, ok := r.Subrepos[key]
+	return sub, ok
+}
+
+// HostInfos returns all the host files in the repository and its subrepos
+func (r *Repo) HostInfos() (hosts []*HostInfo) {
+	for _, key := range r.Keys() {
+		item, _ := r.Item(key)
+		if h, ok := item.(*HostInfo); ok {
+			hosts = append(hosts, h)
+		}
+	}
+
+	for _, key := range r.SubrepoKeys() {
+		sub, _ := r.Subrepo(key)
+		hosts = append(hosts, sub.HostInfos()...)
+	}
+
+	return
+}
+
+// Infos returns every info item in the repository and its subrepos
+func (r *Repo) Infos() (infos []*Info) {
+	for _, key := range r.Keys() {
+		item, _ := r.Item(key)
+		if info, ok := item.(*Info); ok {
+			infos = append(infos, info)
+		}
+	}
+
+	for _, key := range r.SubrepoKeys() {
+		sub, _ := r.Subrepo(key)
+		infos = append(infos, sub.Infos()...)
+	}
+
+	return
+}
+
 // GetHost will return a Host as defined by the list of arguments
 //
 // `args` is to be a string containing space separated identifiers to find a
@@ -214,20 +814,24 @@ func (r *Repo) SubrepoKeys() []string {
 func (r *Repo) GetHost(def string) (h *Host) {
 	args := strings.Split(def, " ")
 	if len(args) < 2 {
-		log.Fatal("Too few identifiers in host string. Need at least 2.")
+		fmt.Println("Too few identifiers in host string. Need at least 2.")
+		exit(exitError)
 	}
 
 	args = append([]string{"hosts"}, args...)
 
 	item, remaining, err := r.GetItem(args)
-	host := item.(HostInfo)
-
-	if err != nil {
-		log.Print(err)
-		log.Fatal("No host could be found")
+	host, ok := item.(*HostInfo)
+	if err != nil || !ok || len(remaining) == 0 {
+		fmt.Println("No host could be found for", def)
+		exit(exitNotFound)
 	}
 
-	cat := host.Types[remaining[0]]
+	cat, ok := host.Types[remaining[0]]
+	if !ok {
+		fmt.Println("No host could be found for", def)
+		exit(exitNotFound)
+	}
 	return cat.PrimaryHost()
 }
 
@@ -246,7 +850,11 @@ func (r *Repo) GetItem(args []string) (Item, []string, error) {
 		)
 	}
 
-	if item, ok = repo.Items[remaining[0]]; ok {
+	if len(remaining) == 0 {
+		return nil, []string{}, fmt.Errorf("%s is a subrepo, not an item", repo.Key)
+	}
+
+	if item, ok = repo.Item(remaining[0]); ok {
 		return item, remaining[1:], nil
 	}
 
@@ -264,11 +872,11 @@ func (r *Repo) GetSubrepo(args []string) (*Repo, []string, error) {
 	}
 
 	arg := args[0]
-	if repo, ok := r.Subrepos[arg]; ok {
+	if repo, ok := r.Subrepo(arg); ok {
 		return repo.GetSubrepo(args[1:])
 	}
 
-	if _, ok := r.Items[arg]; !ok {
+	if _, ok := r.Item(arg); !ok {
 		err = fmt.Errorf("Subrepo did not exist: %s", arg)
 	}
 	return r, args, err
@@ -279,10 +887,325 @@ func (r *Repo) GetSubrepo(args []string) (*Repo, []string, error) {
 // This is used by things like command execution, where the current repository would be
 // `commands` or a subrepository, but the root is needed for host discovery.
 func (r *Repo) ParentRepo() *Repo {
-	if &r.Parent == nil {
-		return r
+	for r.Parent != nil {
+		r = r.Parent
+	}
+	return r
+}
+
+// follows returns true if a symlinked directory should be loaded as a subrepo
+//
+// Symlinks are only followed if the settings say so, and never if they point
+// back to the repository itself or one of its parents, since that would make
+// the loading go on forever.
+func (r *Repo) follows(dir string) bool {
+	if !r.Settings.followsSymlinks() {
+		return false
+	}
+
+	target, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		log.Printf("Not following %s: %s", dir, err)
+		return false
+	}
+
+	for p := r; p != nil; p = p.Parent {
+		if root, err := filepath.EvalSymlinks(p.root); err == nil && root == target {
+			log.Printf("Not following %s: it loops back to %s", dir, p.root)
+			return false
+		}
+	}
+
+	return true
+}
+
+// sortFlag picks how the items in listings are sorted
+var sortFlag = cli.StringFlag{
+	Name:  "sort",
+	Value: "name",
+	Usage: "sort items by name, by their order or by mtime",
+}
+
+// reverseFlag turns the sort order of listings around
+var reverseFlag = cli.BoolFlag{
+	Name:  "reverse",
+	Usage: "reverse the sort order",
+}
+
+// groupByFlag puts the items of listings under headers
+var groupByFlag = cli.StringFlag{
+	Name:  "group-by",
+	Usage: "group items by type or tag",
+}
+
+// keysOnlyFlag leaves the summaries out of listings, for scripting
+var keysOnlyFlag = cli.BoolFlag{
+	Name:  "keys-only",
+	Usage: "only print the keys, for scripting",
+}
+
+// countOnlyFlag prints how many items pass the filter instead of listing them
+var countOnlyFlag = cli.BoolFlag{
+	Name:  "count-only",
+	Usage: "only print the number of matching items",
+}
+
+// Execute prints the contents of the repository
+//
+// Subrepos are printed first, followed by the items that pass the filter given
+// by the flags and their summaries.
+//
+// Anything that exactly matches a subrepo or item is handled by the CLI before
+// ending up here, so if there are arguments, the first one did not match. If
+// it is a prefix of exactly one key, the command of that key is run with the
+// rest of the arguments, as if the full key had been given.
+func (r *Repo) Execute(c *cli.Context) {
+	if args := c.Args(); len(args) != 0 {
+		key, candidates := r.resolve(args[0])
+		if key == "" || key == args[0] {
+			if len(candidates) == 0 {
+				fmt.Println("Nothing in", r.Key, "matches", args[0])
+				exit(exitNotFound)
+			}
+			fmt.Println(args[0], "is ambiguous. Choices are:", strings.Join(candidates, ", "))
+			exit(exitError)
+		}
+
+		r.keyCommand(key).Run(c)
+		return
+	}
+
+	f, err := newFilter(c)
+	if err != nil {
+		fmt.Println(err)
+		exit(exitError)
+	}
+
+	if c.Bool("count-only") {
+		fmt.Println(r.Count(f))
+		return
+	}
+
+	out, err := r.listing(f, ListOptions{
+		Sort:     c.String("sort"),
+		Reverse:  c.Bool("reverse"),
+		KeysOnly: c.Bool("keys-only"),
+		GroupBy:  c.String("group-by"),
+		Cols:     outputCols(),
+	})
+	if err != nil {
+		fmt.Println(err)
+		exit(exitError)
+	}
+
+	page(out)
+}
+
+// ListOptions changes how listing shows the items
+type ListOptions struct {
+	// Sort is how the items are sorted, "name", "order" or "mtime"
+	Sort string
+
+	// Reverse turns the order given by Sort around
+	Reverse bool
+
+	// KeysOnly leaves the summaries out
+	KeysOnly bool
+
+	// GroupBy puts the items under a header for each "type" or "tag", if it
+	// is set. Items with several tags are shown under each of them.
+	GroupBy string
+
+	// Cols is the width of the screen. Summaries are truncated to fit in it,
+	// unless it is zero.
+	Cols int
+}
+
+// Count returns how many of the items in the repository pass the filter
+func (r *Repo) Count(f Filter) int {
+	n := 0
+	for _, key := range r.Keys() {
+		if item, ok := r.Item(key); ok && f.Match(item) {
+			n++
+		}
+	}
+	return n
+}
+
+// unsetGroup is the group of items that do not have what they are grouped by
+const unsetGroup = "(unset)"
+
+// listing formats the subrepos and the items that pass the filter
+//
+// The summary of each item is shown dimmed next to its key, unless the options
+// say keys only or the item does not have one.
+func (r *Repo) listing(f Filter, opts ListOptions) (string, error) {
+	grey := color.New(color.FgWhite).SprintfFunc()
+	faint := color.New(color.Faint).SprintfFunc()
+	bold := color.New(color.Bold).SprintfFunc()
+	red := color.New(color.FgRed).SprintfFunc()
+
+	keys, err := r.SortedKeys(opts.Sort)
+	if err != nil {
+		return "", err
+	}
+	if opts.Reverse {
+		for x, y := 0, len(keys)-1; x < y; x, y = x+1, y-1 {
+			keys[x], keys[y] = keys[y], keys[x]
+		}
+	}
+
+	var items []Item
+	width := 0
+	for _, key := range keys {
+		if item, ok := r.Item(key); ok && f.Match(item) {
+			items = append(items, item)
+			if displayWidth(key) > width {
+				width = displayWidth(key)
+			}
+		}
+	}
+
+	// Subrepos that did not load completely are marked, so that they are not
+	// taken to be empty or complete
+	failed := r.Failures()
+	var out bytes.Buffer
+	for _, key := range r.SubrepoKeys() {
+		sub, _ := r.Subrepo(key)
+		err, broken := failed[key]
+		switch {
+		case !broken:
+			fmt.Fprintln(&out, sub.label(key))
+		case plain:
+			fmt.Fprintf(&out, "%s\tbroken: %s\n", key, err)
+		default:
+			fmt.Fprintf(&out, "%s  %s\n", sub.label(key), red("broken: %s", err))
+		}
+	}
+
+	line := func(item Item, prefix string) {
+		id, summary := item.ID(), item.Summary()
+		if plain {
+			if opts.KeysOnly || summary == "" {
+				fmt.Fprintf(&out, "%s%s\n", prefix, id)
+			} else {
+				fmt.Fprintf(&out, "%s%s\t%s\n", prefix, id, summary)
+			}
+			return
+		}
+
+		// Archived items are only here if they were asked for, and are
+		// dimmed to set them apart
+		name := id
+		if isArchived(item) {
+			name = faint(id)
+		}
+
+		if opts.KeysOnly || summary == "" {
+			fmt.Fprintf(&out, "%s%s\n", prefix, name)
+			return
+		}
+
+		if opts.Cols != 0 {
+			summary = truncate(summary, opts.Cols-displayWidth(prefix)-width-2)
+		}
+		fmt.Fprintf(&out, "%s%s%s  %s\n", prefix, name, strings.Repeat(" ", width-displayWidth(id)), grey(summary))
+	}
+
+	if opts.GroupBy == "" {
+		for _, item := range items {
+			line(item, "")
+		}
+		return out.String(), nil
+	}
+
+	names, groups, err := groupItems(items, opts.GroupBy)
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range names {
+		// Plain output stays one item per line, with the group in front
+		if plain {
+			for _, item := range groups[name] {
+				line(item, name+"\t")
+			}
+			continue
+		}
+
+		if out.Len() != 0 {
+			fmt.Fprintln(&out)
+		}
+		fmt.Fprintln(&out, bold(name))
+		for _, item := range groups[name] {
+			line(item, "  ")
+		}
+	}
+
+	return out.String(), nil
+}
+
+// groupItems sorts the items into groups by their "type" or "tag", keeping
+// the order they are in within each group
+//
+// The names of the groups are sorted, with the items that are in none of them
+// last, under unsetGroup.
+func groupItems(items []Item, by string) ([]string, map[string][]Item, error) {
+	if by != "type" && by != "tag" {
+		return nil, nil, fmt.Errorf("Cannot group by %q, only by type or tag", by)
+	}
+
+	groups := make(map[string][]Item)
+	for _, item := range items {
+		var values []string
+		if by == "type" && item.Type() != "" {
+			values = []string{item.Type()}
+		} else if tg, ok := item.(tagger); ok && by == "tag" {
+			values = tg.Tags()
+		}
+
+		if len(values) == 0 {
+			values = []string{unsetGroup}
+		}
+		for _, v := range values {
+			groups[v] = append(groups[v], item)
+		}
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		if name != unsetGroup {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := groups[unsetGroup]; ok {
+		names = append(names, unsetGroup)
+	}
+
+	return names, groups, nil
+}
+
+// resolve finds the subrepo or item key that a prefix refers to
+//
+// An exact match always wins. Otherwise the prefix has to match exactly one
+// key. If it does not, the key is empty and all the keys that the prefix
+// matched are returned as candidates.
+func (r *Repo) resolve(prefix string) (string, []string) {
+	var candidates []string
+	for _, key := range append(r.SubrepoKeys(), r.Keys()...) {
+		if key == prefix {
+			return key, nil
+		}
+		if strings.HasPrefix(key, prefix) {
+			candidates = append(candidates, key)
+		}
 	}
-	return r.Parent
+
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+	return "", candidates
 }
 
 // MakeCLI generates a cli.Command chain based on the repository structure
@@ -291,6 +1214,8 @@ func (r *Repo) MakeCLI() (c cli.Command) {
 		Name:     r.Key,
 		Usage:    r.Summary,
 		HideHelp: true,
+		Flags:    append(append([]cli.Flag{}, filterFlags...), sortFlag, reverseFlag, groupByFlag, keysOnlyFlag, countOnlyFlag),
+		Action:   r.Execute,
 	}
 
 	// Make a list of subcommands to add into the Command.
@@ -298,39 +1223,83 @@ func (r *Repo) MakeCLI() (c cli.Command) {
 
 	// Loop over the subrepositories first, making sure that they are on top.
 	for _, key := range r.SubrepoKeys() {
-		subrepo := r.Subrepos[key]
+		subrepo, _ := r.Subrepo(key)
 		subcommands = append(subcommands, subrepo.MakeCLI())
 	}
 
 	// Then loop the item files.
 	for _, key := range r.Keys() {
-		item := r.Items[key]
+		item, _ := r.Item(key)
+		subcommands = append(subcommands, itemCommand(item))
+	}
 
-		sc := cli.Command{
-			Name:     item.ID(),
-			Usage:    item.Summary(),
-			HideHelp: true,
-			Action:   item.Execute,
-		}
+	c.Subcommands = subcommands
 
-		sc.Subcommands = append(sc.Subcommands, item.MakeCLI()...)
+	return
+}
 
-		subcommands = append(subcommands, sc)
+// itemCommand makes the cli.Command of an item
+func itemCommand(item Item) cli.Command {
+	c := cli.Command{
+		Name:     item.ID(),
+		Usage:    item.Summary(),
+		HideHelp: true,
+		Action:   item.Execute,
+	}
+	if f, ok := item.(flagger); ok {
+		c.Flags = f.Flags()
 	}
 
-	c.Subcommands = subcommands
+	c.Subcommands = append(c.Subcommands, item.MakeCLI()...)
 
-	return
+	return c
 }
 
-func (r *Repo) loadItem(path string) Item {
-	info, err := LoadItem(r, path)
+// keyCommand makes the cli.Command of the subrepo or item with the key
+func (r *Repo) keyCommand(key string) cli.Command {
+	if sub, ok := r.Subrepo(key); ok {
+		return sub.MakeCLI()
+	}
+
+	item, _ := r.Item(key)
+	return itemCommand(item)
+}
+
+func (r *Repo) loadItem(path string, mtime time.Time) Item {
+	defer loading.add()
+
+	info, err := readItem(r, path, mtime)
 	if err != nil {
 		log.Println("Failed to load info: ", err)
+
+		// A broken _repo.yaml is already reported when it is read
+		if !strings.HasPrefix(asKey(path), "_") {
+			r.fail(err)
+		}
 	}
 	return info
 }
 
+// isGit returns true if the repository directory is a git repository of its own
+func (r *Repo) isGit() bool {
+	_, err := os.Stat(filepath.Join(r.root, ".git"))
+	return err == nil
+}
+
+// gitSubrepos returns all the subrepos, at any depth, that are git repositories
+// of their own
+func (r *Repo) gitSubrepos() (repos []*Repo) {
+	for _, key := range r.SubrepoKeys() {
+		sub, _ := r.Subrepo(key)
+		if sub.isGit() {
+			repos = append(repos, sub)
+		}
+		repos = append(repos, sub.gitSubrepos()...)
+	}
+
+	return
+}
+
 // Helper to run git commands inside of a repository
 func (r *Repo) git(args ...string) {
 	git(r.root, args...)
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"github.com/fatih/color"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 // createJunk creates a lot of garbage files in a temporary diretory
@@ -79,6 +85,17 @@ func TestNewRepoNestsDeep(t *testing.T) {
 	assert.Equal("deepest", five.Items["deepest"].ID())
 }
 
+func TestNewRepoStream(t *testing.T) {
+	assert := assert.New(t)
+
+	var ids []string
+	for item := range NewRepoStream("test/deep/") {
+		ids = append(ids, item.ID())
+	}
+
+	assert.Equal([]string{"deepest"}, ids)
+}
+
 func TestNewRepoNestsDeepAndDoesNotPutItemsOnTopLevels(t *testing.T) {
 	assert := assert.New(t)
 
@@ -97,3 +114,563 @@ func TestNewRepoNestsDeepAndDoesNotPutItemsOnTopLevels(t *testing.T) {
 	assert.Equal(len(four.Items), 0)
 	assert.Equal(len(five.Items), 1)
 }
+
+func TestLoadReposInheritsTheSSHDefaultsOfTheConfig(t *testing.T) {
+	assert := assert.New(t)
+	conf := &Config{
+		Repositories: []string{"test/sshdefaults"},
+		SSHDefaults:  SSHOptions{User: "confuser", IdentityFile: "~/.ssh/ops", KeepAlive: 60},
+		Quiet:        true,
+	}
+
+	r := LoadRepos(conf)["sshdefaults"]
+
+	// The _repo.yaml wins over the configuration
+	assert.Equal("repouser", r.Settings.SSHDefaults.User)
+	assert.Equal("~/.ssh/ops", r.Settings.SSHDefaults.IdentityFile)
+	assert.Equal(60, r.Settings.SSHDefaults.KeepAlive)
+
+	host := r.Items["hosts"].(*HostInfo).Types["db"].Hosts[0]
+	assert.Equal("~/.ssh/ops", host.IdentityFile)
+	assert.Equal(60, host.KeepAlive)
+}
+
+func TestNewRepoSettingsAreInheritedAcrossLevels(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewRepo("test/inherit/")
+	middle := r.Subrepos["middle"]
+	leaf := middle.Subrepos["leaf"]
+
+	// The root only has its own settings
+	assert.Equal("red", r.Settings.Color)
+	assert.Equal("root", r.Settings.SSHDefaults.User)
+	assert.Equal(2222, r.Settings.SSHDefaults.Port)
+
+	// The middle overrides color and user, but keeps the port
+	assert.Equal("green", middle.Settings.Color)
+	assert.Equal("deploy", middle.Settings.SSHDefaults.User)
+	assert.Equal(2222, middle.Settings.SSHDefaults.Port)
+
+	// The leaf only overrides the port, everything else comes from the
+	// closest parent that set it
+	assert.Equal("green", leaf.Settings.Color)
+	assert.Equal("deploy", leaf.Settings.SSHDefaults.User)
+	assert.Equal(22, leaf.Settings.SSHDefaults.Port)
+	assert.Equal([]string{"*.draft.yaml"}, leaf.Settings.Ignore)
+}
+
+func TestNewRepoSkipsInheritedIgnorePatterns(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewRepo("test/inherit/")
+	leaf := r.Subrepos["middle"].Subrepos["leaf"]
+
+	assert.Equal(1, len(leaf.Items))
+	assert.Equal("kept", leaf.Items["kept"].ID())
+}
+
+func TestParentRepoFindsTheRoot(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewRepo("test/inherit/")
+	leaf := r.Subrepos["middle"].Subrepos["leaf"]
+
+	assert.Equal(r, leaf.ParentRepo())
+	assert.Equal(r, r.ParentRepo())
+}
+
+func TestNewRepoFollowsSymlinkedSubrepos(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewRepo("test/symlinks/")
+	linked := r.Subrepos["linked"]
+
+	assert.NotNil(linked)
+	five := linked.Subrepos["two"].Subrepos["three"].Subrepos["four"].Subrepos["five"]
+	assert.Equal("deepest", five.Items["deepest"].ID())
+}
+
+func TestNewRepoDoesNotFollowSymlinkLoops(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewRepo("test/symlinks/")
+	_, ok := r.Subrepos["loop"]
+
+	assert.False(ok)
+	assert.Equal(2, len(r.Subrepos))
+}
+
+func TestNewRepoDoesNotFollowSymlinksWhenDisabled(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewRepo("test/symlinks/")
+	off := r.Subrepos["off"]
+
+	assert.Equal(0, len(off.Subrepos))
+}
+
+func TestNewRepoWarnsAboutCollidingSubrepoKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	r := NewRepo("test/collide/")
+
+	// Both directories become "db"; the one that sorts first is kept
+	assert.Equal(1, len(r.Subrepos))
+	assert.Equal("backups", r.Subrepos["db"].Items["backups"].ID())
+
+	out := buf.String()
+	assert.True(strings.Contains(out, filepath.Join("collide", "db.new")))
+	assert.True(strings.Contains(out, filepath.Join("collide", "db.old")))
+}
+
+func TestGitSubreposFindsNestedGitRepositories(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, _ := ioutil.TempDir("", "sagacity")
+	defer os.RemoveAll(dir)
+
+	// Only "docs" and "docs/old" are git repositories of their own
+	for _, p := range []string{"docs/.git", "docs/old/.git", "notes/misc"} {
+		os.MkdirAll(filepath.Join(dir, p), 0755)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "_repo.yaml"), []byte{}, 0644)
+
+	repos := NewRepo(dir).gitSubrepos()
+
+	assert.Equal(2, len(repos))
+	assert.Equal(filepath.Join(dir, "docs"), repos[0].root)
+	assert.Equal(filepath.Join(dir, "docs", "old"), repos[1].root)
+}
+
+func ExampleListRepos() {
+	repos := map[string]*Repo{
+		"zathura": {Key: "zathura", Summary: "Document viewer notes"},
+		"gamma":   {Key: "gamma"},
+	}
+
+	ListRepos(repos, true)
+	// Output: gamma
+	// zathura
+}
+
+func ExampleListRepos_summaries() {
+	repos := map[string]*Repo{
+		"zathura": {Key: "zathura", Summary: "Document viewer notes"},
+		"gamma":   {Key: "gamma"},
+	}
+
+	ListRepos(repos, false)
+	// Output: [34;1mgamma[0m
+	// [34;1mzathura[0m  [37mDocument viewer notes[0m
+}
+
+func ExampleListRepos_icons() {
+	repos := map[string]*Repo{
+		"infra": {Key: "infra", Icon: "🔥", Settings: Settings{Color: "red"}},
+		"wiki":  {Key: "wiki", Settings: Settings{Color: "green"}},
+	}
+
+	ListRepos(repos, false)
+	// Output: 🔥 [31;1minfra[0m
+	//    [32;1mwiki[0m
+}
+
+func ExampleListRepos_iconsAndSummaries() {
+	repos := map[string]*Repo{
+		"infra": {Key: "infra", Icon: "🔥", Summary: "Servers", Settings: Settings{Color: "red"}},
+		"wiki":  {Key: "wiki", Summary: "Manuals", Settings: Settings{Color: "green"}},
+	}
+
+	ListRepos(repos, false)
+	// Output: 🔥 [31;1minfra[0m  [37mServers[0m
+	//    [32;1mwiki [0m  [37mManuals[0m
+}
+
+func TestNewRepoItemsWithTheSameKey(t *testing.T) {
+	assert := assert.New(t)
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	defer useFS(memFS{
+		"/mem/dup/_repo.yaml": "",
+		"/mem/dup/foo.yaml":   "type: info\nsummary: From YAML\n",
+		"/mem/dup/foo.md":     "From Markdown\n",
+		"/mem/dup/bar.yaml":   "type: info\n",
+	})()
+
+	// The path that sorts first is kept, however the files happen to load
+	for x := 0; x < 10; x++ {
+		r := NewRepo("/mem/dup")
+
+		assert.Equal(2, len(r.Items))
+		assert.Equal("/mem/dup/foo.md", r.Items["foo"].Path())
+		assert.Contains(r.Err().Error(), `both have the key "foo"`)
+	}
+}
+
+func TestNewRepoIgnoresUnknownColor(t *testing.T) {
+	assert := assert.New(t)
+
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	r := NewRepo("test/badcolor/")
+
+	assert.Equal("", r.Settings.Color)
+}
+
+func TestRepoResolveUniquePrefix(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/prefix/")
+
+	key, candidates := r.resolve("d")
+	assert.Equal("dns", key)
+	assert.Nil(candidates)
+
+	key, _ = r.resolve("firew")
+	assert.Equal("firewalls", key)
+}
+
+func TestRepoResolveExactMatchWins(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/prefix/")
+
+	// "fire" is also a prefix of the firewalls subrepo
+	key, candidates := r.resolve("fire")
+	assert.Equal("fire", key)
+	assert.Nil(candidates)
+}
+
+func TestRepoResolveAmbiguousPrefix(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/prefix/")
+
+	key, candidates := r.resolve("fi")
+	assert.Equal("", key)
+	assert.Equal([]string{"firewalls", "filing", "fire"}, candidates)
+}
+
+func TestRepoPrefixRunsTheCommandOfTheKey(t *testing.T) {
+	assert := assert.New(t)
+	f := &fakeRunner{}
+	defer useRunner(f)()
+
+	runCLI(NewRepo("test/kinds/"), "ho", "db", "db1.company.net")
+
+	assert.Equal([][]string{{"ssh", "db1.company.net", "-A", "-t"}}, f.calls)
+}
+
+func TestRepoResolveNoMatch(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/prefix/")
+
+	key, candidates := r.resolve("x")
+	assert.Equal("", key)
+	assert.Equal(0, len(candidates))
+}
+
+func TestRepoPullArgsUseRemote(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewRepo("test/inherit/")
+	leaf := r.Subrepos["middle"].Subrepos["leaf"]
+
+	// The root has no remote of its own, so it gets the configured one
+	assert.Equal([]string{"pull", "--quiet", "origin", "master"}, r.pullArgs("origin"))
+
+	// The leaf inherits the remote of the middle
+	assert.Equal([]string{"pull", "--quiet", "upstream", "master"}, leaf.pullArgs("origin"))
+}
+
+func TestRepoUpdateFailsWhenHeadCannotBeFound(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "sagacity")
+	defer os.RemoveAll(dir)
+	defer func(orig string) { gitBinary = orig }(gitBinary)
+
+	// Pulling works, but there is no HEAD to compare with
+	gitBinary = filepath.Join(dir, "git")
+	ioutil.WriteFile(gitBinary, []byte("#!/bin/sh\ntest \"$1\" != rev-parse\n"), 0755)
+
+	_, err := NewRepo("test/inherit/").update(0, "origin")
+	assert.NotNil(err)
+}
+
+func TestParseNameStatus(t *testing.T) {
+	assert := assert.New(t)
+	out := strings.Join([]string{
+		"A\tdb/backups.yaml",
+		"A\tdb/restores.yaml",
+		"M\thosts/web.yaml",
+		"R100\tnotes.yaml\tarchive/notes.yaml",
+		"D\told.yaml",
+	}, "\n")
+
+	assert.Equal(DiffStat{Added: 2, Modified: 2, Deleted: 1}, parseNameStatus(out))
+}
+
+func TestParseNameStatusNoChanges(t *testing.T) {
+	assert := assert.New(t)
+
+	d := parseNameStatus("")
+
+	assert.Equal(DiffStat{}, d)
+	assert.Equal("up to date", d.String())
+}
+
+func TestSortedKeysByName(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/order/")
+
+	keys, err := r.SortedKeys("name")
+
+	assert.Nil(err)
+	assert.Equal([]string{"appendix", "drain", "notes", "restart", "undrain"}, keys)
+}
+
+func TestSortedKeysByOrderPutsUnorderedLast(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/order/")
+
+	keys, err := r.SortedKeys("order")
+
+	assert.Nil(err)
+	assert.Equal([]string{"drain", "restart", "undrain", "appendix", "notes"}, keys)
+}
+
+func TestSortedKeysByModTime(t *testing.T) {
+	assert := assert.New(t)
+	dir, cleanup := writeRepo(map[string]string{
+		"_repo.yaml": "",
+		"old.yaml":   "type: info\n",
+		"new.yaml":   "type: info\n",
+		"mid.yaml":   "type: info\n",
+	})
+	defer cleanup()
+
+	now := time.Now()
+	for x, fn := range []string{"old.yaml", "mid.yaml", "new.yaml"} {
+		mtime := now.Add(time.Duration(x-3) * time.Hour)
+		os.Chtimes(filepath.Join(dir, fn), mtime, mtime)
+	}
+	r := NewRepo(dir)
+
+	keys, err := r.SortedKeys("mtime")
+	assert.Nil(err)
+	assert.Equal([]string{"new", "mid", "old"}, keys)
+
+	out, err := r.listing(Filter{}, ListOptions{Sort: "mtime", Reverse: true, KeysOnly: true})
+	assert.Nil(err)
+	assert.Equal("old\nmid\nnew\n", out)
+}
+
+func TestSortedKeysUnknownMode(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/order/")
+
+	_, err := r.SortedKeys("size")
+
+	assert.NotNil(err)
+}
+
+// Run with -race to check that the repo can be read while items are added
+func TestRepoCanBeReadWhileLoading(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/order/")
+
+	done := make(chan bool)
+	go func() {
+		for x := 0; x < 100; x++ {
+			key := fmt.Sprintf("late%d", x)
+			r.addItem(&Info{id: key, path: "test/order/" + key + ".yaml"})
+		}
+		close(done)
+	}()
+
+	for x := 0; x < 100; x++ {
+		r.Keys()
+		r.SortedKeys("order")
+		r.SubrepoKeys()
+		r.Item("drain")
+		r.HostInfos()
+	}
+	<-done
+
+	assert.Equal(105, len(r.Keys()))
+}
+
+// Run with -race to check that loading many repos, subrepos and items at once
+// is safe, and that nothing is lost on the way
+func TestLoadReposLoadsEverything(t *testing.T) {
+	assert := assert.New(t)
+
+	files := make(map[string]string)
+	var dirs []string
+	for x := 0; x < 5; x++ {
+		repo := fmt.Sprintf("repo%d", x)
+		dirs = append(dirs, repo)
+		files[repo+"/_repo.yaml"] = ""
+		for y := 0; y < 10; y++ {
+			sub := fmt.Sprintf("%s/sub%d", repo, y)
+			files[sub+"/_repo.yaml"] = ""
+			for z := 0; z < 5; z++ {
+				files[fmt.Sprintf("%s/item%d.yaml", sub, z)] = "type: info\n"
+			}
+		}
+	}
+	dir, cleanup := writeRepo(files)
+	defer cleanup()
+	for x := range dirs {
+		dirs[x] = filepath.Join(dir, dirs[x])
+	}
+
+	repos := LoadRepos(&Config{Repositories: dirs, Quiet: true})
+
+	assert.Equal(5, len(repos))
+	for _, r := range repos {
+		assert.Equal(10, len(r.SubrepoKeys()), r.Key)
+		assert.Equal(50, len(r.Infos()), r.Key)
+		for y := 0; y < 10; y++ {
+			sub, ok := r.Subrepo(fmt.Sprintf("sub%d", y))
+			if assert.True(ok, r.Key) {
+				assert.Equal([]string{"item0", "item1", "item2", "item3", "item4"}, sub.Keys())
+			}
+		}
+	}
+}
+
+func TestListingShowsSummaries(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/order/")
+
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	out, err := r.listing(Filter{}, ListOptions{Sort: "order"})
+
+	assert.Nil(err)
+	assert.Equal(
+		"drain     Stop sending traffic to the host\n"+
+			"restart   Restart the service\n"+
+			"undrain\n"+
+			"appendix\n"+
+			"notes\n",
+		out,
+	)
+}
+
+func TestListingTruncatesSummaries(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/order/")
+
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	out, _ := r.listing(Filter{}, ListOptions{Sort: "name", Cols: 20})
+
+	assert.Contains(out, "drain     Stop send…\n")
+	assert.Contains(out, "restart   Restart t…\n")
+}
+
+func TestListingKeysOnly(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/order/")
+
+	out, _ := r.listing(Filter{}, ListOptions{Sort: "name", KeysOnly: true})
+
+	assert.Equal("appendix\ndrain\nnotes\nrestart\nundrain\n", out)
+}
+
+func TestListingReverse(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/order/")
+
+	out, _ := r.listing(Filter{}, ListOptions{Sort: "name", Reverse: true, KeysOnly: true})
+
+	assert.Equal("undrain\nrestart\nnotes\ndrain\nappendix\n", out)
+}
+
+func TestCountRespectsFilters(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/archived/")
+
+	assert.Equal(1, r.Count(Filter{}))
+	assert.Equal(3, r.Count(Filter{IncludeArchived: true}))
+}
+
+func TestListingHidesArchived(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/archived/")
+
+	out, _ := r.listing(Filter{}, ListOptions{Sort: "name", KeysOnly: true})
+	assert.Equal("current\n", out)
+
+	out, _ = r.listing(Filter{IncludeArchived: true}, ListOptions{Sort: "name", KeysOnly: true})
+	assert.Contains(out, "legacy")
+	assert.Contains(out, "old")
+}
+
+func TestListingGroupByType(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/groups/")
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	out, err := r.listing(Filter{}, ListOptions{Sort: "name", KeysOnly: true, GroupBy: "type"})
+
+	assert.Nil(err)
+	assert.Equal("note\n  caching\n\nrunbook\n  failover\n\n(unset)\n  misc\n", out)
+}
+
+func TestListingGroupByTag(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRepo("test/groups/")
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	out, err := r.listing(Filter{}, ListOptions{Sort: "name", KeysOnly: true, GroupBy: "tag"})
+
+	assert.Nil(err)
+	assert.Equal("db\n  caching\n  failover\n\nweb\n  caching\n\n(unset)\n  misc\n", out)
+
+	_, err = r.listing(Filter{}, ListOptions{GroupBy: "owner"})
+	assert.NotNil(err)
+}
+
+func TestBrokenSubrepoIsMarked(t *testing.T) {
+	assert := assert.New(t)
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	r := NewRepo("test/broken/")
+	dns := r.Subrepos["dns"]
+
+	// What could be loaded is still there
+	assert.Equal([]string{"records"}, dns.Keys())
+	assert.Contains(dns.Err().Error(), "zones.yaml")
+
+	failed := r.Failures()
+	assert.Equal(1, len(failed))
+	assert.Contains(failed["dns"].Error(), "zones.yaml")
+	assert.Contains(r.Err().Error(), "subrepo dns: ")
+	assert.Nil(r.Subrepos["ok"].Err())
+
+	noColor := color.NoColor
+	defer func() {
+		plain = false
+		color.NoColor = noColor
+	}()
+	setPlain()
+
+	out, _ := r.listing(Filter{}, ListOptions{})
+	assert.Contains(out, "dns\tbroken: ")
+	assert.Contains(out, "\nok\n")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// retryDelay is how long to wait before the first retry. Every retry after
+// that waits twice as long as the one before.
+var retryDelay = time.Second
+
+// permanentGitErrors are signs that a git command failed in a way that will
+// not go away by trying again
+var permanentGitErrors = []string{
+	"Authentication failed",
+	"Permission denied",
+	"could not read Username",
+	"Repository not found",
+	"does not appear to be a git repository",
+	"not a git repository",
+	"couldn't find remote ref",
+	"CONFLICT",
+	"Please commit your changes or stash them",
+}
+
+// retry runs f until it succeeds, for at most retries extra attempts, waiting
+// longer and longer between them
+//
+// f returns what the command printed on stderr along with its error, which is
+// used to tell permanent failures from temporary ones. Permanent failures are
+// never retried.
+func retry(what string, retries int, f func() (string, error)) error {
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		stderr, err := f()
+		if err == nil || attempt >= retries || isPermanent(stderr) {
+			return err
+		}
+
+		log.Printf("%s failed (%s), retrying in %s (%d/%d)", what, err, delay, attempt+1, retries)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// isPermanent returns true if the output of a failed git command shows that it
+// is not worth trying again
+func isPermanent(stderr string) bool {
+	for _, msg := range permanentGitErrors {
+		if strings.Contains(stderr, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// gitRetry runs a git command, retrying it if it fails for what looks like a
+// temporary reason such as the network
+func gitRetry(pwd string, retries int, args ...string) error {
+	return retry("git "+args[0], retries, func() (string, error) {
+		var stderr bytes.Buffer
+
+		err := runGit(pwd, func(cmd *exec.Cmd) {
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+		}, args...)
+		return stderr.String(), err
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// quickRetries makes retries wait no time at all and keeps them out of the
+// log, returning a function that restores things
+func quickRetries() func() {
+	delay := retryDelay
+	retryDelay = 0
+	log.SetOutput(ioutil.Discard)
+
+	return func() {
+		retryDelay = delay
+		log.SetOutput(os.Stderr)
+	}
+}
+
+func TestRetrySucceedsAfterTemporaryFailures(t *testing.T) {
+	assert := assert.New(t)
+	defer quickRetries()()
+
+	calls := 0
+	err := retry("pull", 2, func() (string, error) {
+		calls++
+		if calls < 3 {
+			return "fatal: unable to access: Could not resolve host", errors.New("exit status 128")
+		}
+		return "", nil
+	})
+
+	assert.Nil(err)
+	assert.Equal(3, calls)
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	assert := assert.New(t)
+	defer quickRetries()()
+
+	calls := 0
+	err := retry("pull", 2, func() (string, error) {
+		calls++
+		return "fatal: the remote end hung up unexpectedly", errors.New("exit status 128")
+	})
+
+	assert.EqualError(err, "exit status 128")
+	assert.Equal(3, calls)
+}
+
+func TestRetryDoesNotRetryPermanentFailures(t *testing.T) {
+	assert := assert.New(t)
+	defer quickRetries()()
+
+	calls := 0
+	err := retry("pull", 5, func() (string, error) {
+		calls++
+		return "fatal: Authentication failed for 'https://company.net/saga-infra.git/'", errors.New("exit status 128")
+	})
+
+	assert.NotNil(err)
+	assert.Equal(1, calls)
+}
+
+func TestGitTimesOut(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, _ := ioutil.TempDir("", "sagacity")
+	defer os.RemoveAll(dir)
+	fake := filepath.Join(dir, "git")
+	ioutil.WriteFile(fake, []byte("#!/bin/sh\nexec sleep 10\n"), 0755)
+
+	defer func(bin string, timeout time.Duration) {
+		gitBinary, gitTimeout = bin, timeout
+	}(gitBinary, gitTimeout)
+	gitBinary, gitTimeout = fake, 50*time.Millisecond
+
+	start := time.Now()
+	err := gitRun(dir, "pull", "--quiet", "origin", "master")
+
+	assert.Equal("git pull timed out after 50ms", err.Error())
+	assert.True(time.Since(start) < 5*time.Second)
+}
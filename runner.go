@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+)
+
+// killDelay is how long a command gets to exit after being interrupted, before
+// it is killed
+const killDelay = 2 * time.Second
+
+// A Runner runs a command line, such as the ssh commands made for hosts. The
+// command is killed if the context is cancelled before it is done. env holds
+// variables like NAME=value, which are added to the environment of the command
+// only.
+type Runner interface {
+	Run(ctx context.Context, args, env []string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+// execRunner runs commands for real
+//
+// When the context is cancelled, the command is interrupted so that it can
+// clean up, like ssh closing its connection. If it is not done after
+// killDelay, it is killed.
+type execRunner struct{}
+
+func (execRunner) Run(ctx context.Context, args, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	if len(env) != 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	cmd.Process.Signal(os.Interrupt)
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(killDelay):
+		cmd.Process.Kill()
+		return <-done
+	}
+}
+
+// interruptContext returns a context that is cancelled when the process is
+// interrupted (Ctrl-C), instead of the process just dying. Calling the
+// returned function restores the default handling.
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+
+	go func() {
+		select {
+		case <-c:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(c)
+		cancel()
+	}
+}
+
+// sshRunner runs the ssh commands of hosts. Tests replace it so that they can
+// check the commands without connecting anywhere.
+var sshRunner Runner = execRunner{}
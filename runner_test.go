@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeRunner records the commands it is asked to run, and the environment
+// they get, instead of running them
+type fakeRunner struct {
+	mu     sync.Mutex
+	calls  [][]string
+	envs   [][]string
+	output string
+	err    error
+}
+
+func (f *fakeRunner) Run(ctx context.Context, args, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, args)
+	f.envs = append(f.envs, env)
+	f.mu.Unlock()
+
+	fmt.Fprint(stdout, f.output)
+	return f.err
+}
+
+// useRunner replaces sshRunner, returning a function that restores it
+func useRunner(r Runner) func() {
+	orig := sshRunner
+	sshRunner = r
+	return func() { sshRunner = orig }
+}
+
+func TestHostExecuteRunsSSH(t *testing.T) {
+	assert := assert.New(t)
+	f := &fakeRunner{}
+	defer useRunner(f)()
+
+	host := &Host{FQDN: "web1.company.net", SSHOptions: SSHOptions{User: "deploy"}}
+	host.Execute()
+
+	assert.Equal([][]string{{"ssh", "deploy@web1.company.net", "-A", "-t"}}, f.calls)
+}
+
+// sessionRunner writes to w when it runs, so that tests can tell what was
+// written before and after the session
+type sessionRunner struct {
+	w io.Writer
+}
+
+func (s sessionRunner) Run(ctx context.Context, args, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fmt.Fprintln(s.w, "session")
+	return nil
+}
+
+func TestHostExecuteSaysWhichHost(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+	orig := connectionLog
+	connectionLog = &out
+	defer func() { connectionLog = orig }()
+	defer useRunner(sessionRunner{&out})()
+
+	h := NewRepo("test/kinds/").Items["hosts"].(*HostInfo)
+	h.Types["web"].Hosts[1].Execute()
+	(&Host{FQDN: "elsewhere.company.net"}).Execute()
+
+	assert.Equal(
+		"connecting to web[1] = web2.company.net\nsession\nconnecting to elsewhere.company.net\nsession\n",
+		out.String(),
+	)
+}
+
+func TestHostRunReportsFailure(t *testing.T) {
+	assert := assert.New(t)
+	f := &fakeRunner{output: "disk full\n", err: errors.New("exit status 1")}
+	defer useRunner(f)()
+
+	var out bytes.Buffer
+	host := &Host{FQDN: "web1.company.net"}
+	err := host.run(context.Background(), &out, "df -h")
+
+	assert.EqualError(err, "exit status 1")
+	assert.Equal("disk full\n", out.String())
+	assert.Equal([][]string{{"ssh", "web1.company.net", "-A", "df -h"}}, f.calls)
+}
+
+func TestInterruptContextIsCancelledByInterrupt(t *testing.T) {
+	assert := assert.New(t)
+	ctx, stop := interruptContext()
+	defer stop()
+
+	syscall.Kill(os.Getpid(), syscall.SIGINT)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the context was not cancelled")
+	}
+	assert.NotNil(ctx.Err())
+}
+
+func TestExecRunnerStopsWhenCancelled(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	err := execRunner{}.Run(ctx, []string{"sleep", "10"}, nil, nil, ioutil.Discard, ioutil.Discard)
+
+	assert.NotNil(err)
+	assert.True(time.Since(start) < killDelay)
+}
@@ -1,15 +1,57 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
+	"time"
 )
 
 func main() {
 	u, _ := user.Current()
 	fn := filepath.Join(u.HomeDir, ".config", "sagacity", "sagacity.yaml")
 	conf := LoadConfig(fn)
+	if hasFlag("--quiet") {
+		conf.Quiet = true
+		connectionLog = ioutil.Discard
+	}
+	noPager = hasFlag("--no-pager")
+	showSecrets = hasFlag("--show-secrets")
+	fullWidth = hasFlag("--full")
+	maxWidth = conf.MaxWidth
+	transcriptDir = conf.Transcripts
+	footersEnabled = conf.Hooks
+	if conf.SecretTool != "" {
+		secretTool = conf.SecretTool
+	}
+	if timeout := flagValue("--git-timeout"); timeout != "" || conf.GitTimeout != "" {
+		if timeout == "" {
+			timeout = conf.GitTimeout
+		}
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			fmt.Println("Bad git timeout:", err)
+			exit(exitError)
+		}
+		gitTimeout = d
+	}
+	sshVerbosity, os.Args = takeVerbosity(os.Args)
+	if cwd, err := os.Getwd(); err == nil && !hasFlag("--global") {
+		conf.Project, _ = findProject(cwd)
+	}
+	if root := flagValue("--root"); root != "" {
+		dir, err := checkRoot(root)
+		if err != nil {
+			fmt.Println(err)
+			exit(exitError)
+		}
+		conf.Project = dir
+	}
+	if hasFlag("--plain") {
+		setPlain()
+	}
 
 	repos := LoadRepos(conf)
 	app := BuildCLI(repos, conf)
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/fatih/color"
+	"sort"
+	"strings"
+)
+
+// Search returns the info items of the repository and all of its subrepos
+// whose key, summary or body contains the term, ignoring case. With exact,
+// only the items whose key is the term match. Items that the filter hides,
+// like archived ones, are left out the same way they are in listings.
+//
+// Control files are not items, so they never match.
+func (r *Repo) Search(term string, exact bool, f Filter) (found []*Info) {
+	term = strings.ToLower(term)
+	for _, info := range r.Infos() {
+		if info.matches(term, exact) && f.Match(info) {
+			found = append(found, info)
+		}
+	}
+	return
+}
+
+// matches returns true if the item matches a lowercase search term
+func (i Info) matches(term string, exact bool) bool {
+	if exact {
+		return strings.ToLower(i.ID()) == term
+	}
+
+	for _, s := range []string{i.ID(), i.Summary(), i.Body} {
+		if strings.Contains(strings.ToLower(s), term) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyPath returns the keys from the root repository down to the item, like
+// prod/db/backups
+func (i Info) KeyPath() string {
+	keys := []string{i.ID()}
+	for r := i.repo; r != nil; r = r.Parent {
+		keys = append([]string{r.Key}, keys...)
+	}
+	return strings.Join(keys, "/")
+}
+
+// SearchRepos prints the items in every repo that match the term, by their
+// full key path, or only how many there are with countOnly
+func SearchRepos(repos map[string]*Repo, term string, exact bool, f Filter, countOnly, reverse bool) {
+	found := searchAll(repos, term, exact, f, reverse)
+
+	if countOnly {
+		fmt.Println(len(found))
+		return
+	}
+	if len(found) == 0 {
+		fmt.Println("Nothing matches", term)
+		exit(exitNotFound)
+	}
+	page(searchListing(found, outputCols()))
+}
+
+// searchAll searches every repo in the order of their keys, with the last
+// match first if reverse is set
+func searchAll(repos map[string]*Repo, term string, exact bool, f Filter, reverse bool) (found []*Info) {
+	keys := make([]string, 0, len(repos))
+	for key := range repos {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		found = append(found, repos[key].Search(term, exact, f)...)
+	}
+
+	if reverse {
+		for x, y := 0, len(found)-1; x < y; x, y = x+1, y-1 {
+			found[x], found[y] = found[y], found[x]
+		}
+	}
+	return
+}
+
+// searchListing formats the items found by their key path, in the color of
+// their repo, with their summaries truncated to fit in cols unless it is zero
+func searchListing(found []*Info, cols int) string {
+	grey := color.New(color.FgWhite).SprintfFunc()
+
+	var out bytes.Buffer
+	for _, info := range found {
+		path, summary := info.KeyPath(), info.Summary()
+		root := info.repo.ParentRepo()
+		switch {
+		case plain:
+			fmt.Fprintf(&out, "%s\t%s\n", path, summary)
+		case summary == "":
+			fmt.Fprintln(&out, root.label("%s", path))
+		default:
+			if cols != 0 {
+				summary = truncate(summary, cols-root.iconWidth()-displayWidth(path)-2)
+			}
+			fmt.Fprintf(&out, "%s  %s\n", root.label("%s", path), grey(summary))
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// searchFiles is a repo with matches at different depths
+var searchFiles = map[string]string{
+	"_repo.yaml":          "",
+	"restore.yaml":        "type: info\nsummary: Getting the BACKUPS back\n",
+	"deploy.yaml":         "type: info\nbody: Nothing to see here\n",
+	"db/_repo.yaml":       "",
+	"db/backups.yaml":     "type: info\nsummary: Nightly dumps\n",
+	"db/retention.md":     "Old backups are removed after a week\n",
+	"db/hosts.yaml":       "type: host\nsummary: Backups hosts\ntypes: {}\n",
+	"db/_backups.yaml":    "type: info\nsummary: Control backups\n",
+	"db/other/_repo.yaml": "",
+	"db/old.yaml":         "type: info\nsummary: The old backups\narchived: true\n",
+	"100%/_repo.yaml":     "",
+	"100%/dumps.yaml":     "type: info\nsummary: Backups of backups\n",
+}
+
+func TestSearch(t *testing.T) {
+	assert := assert.New(t)
+	dir, cleanup := writeRepo(searchFiles)
+	defer cleanup()
+	r := NewRepo(dir)
+
+	var paths []string
+	for _, info := range r.Search("backups", false, Filter{}) {
+		paths = append(paths, info.KeyPath())
+	}
+
+	assert.Equal([]string{
+		r.Key + "/restore",
+		r.Key + "/100%/dumps",
+		r.Key + "/db/backups",
+		r.Key + "/db/retention",
+	}, paths)
+
+	// Archived items are hidden like they are in listings
+	assert.Equal(5, len(r.Search("backups", false, Filter{IncludeArchived: true})))
+}
+
+func TestSearchExact(t *testing.T) {
+	assert := assert.New(t)
+	dir, cleanup := writeRepo(searchFiles)
+	defer cleanup()
+	r := NewRepo(dir)
+
+	found := r.Search("Backups", true, Filter{})
+
+	if assert.Equal(1, len(found)) {
+		assert.Equal(r.Key+"/db/backups", found[0].KeyPath())
+	}
+	assert.Equal(0, len(r.Search("backup", true, Filter{})))
+}
+
+func TestSearchListingPlain(t *testing.T) {
+	assert := assert.New(t)
+	dir, cleanup := writeRepo(searchFiles)
+	defer cleanup()
+	r := NewRepo(dir)
+
+	noColor := color.NoColor
+	defer func() {
+		plain = false
+		color.NoColor = noColor
+	}()
+	setPlain()
+
+	assert.Equal(r.Key+"/db/backups\tNightly dumps\n", searchListing(r.Search("nightly", false, Filter{}), 0))
+}
+
+func TestSearchListingKeepsPercentSigns(t *testing.T) {
+	assert := assert.New(t)
+	dir, cleanup := writeRepo(searchFiles)
+	defer cleanup()
+	r := NewRepo(dir)
+
+	noColor := color.NoColor
+	defer func() { color.NoColor = noColor }()
+	color.NoColor = true
+
+	assert.Equal(r.Key+"/100%/dumps  Backups of backups\n", searchListing(r.Search("dumps", true, Filter{}), 0))
+}
+
+func TestSearchListingTruncatesSummaries(t *testing.T) {
+	assert := assert.New(t)
+	dir, cleanup := writeRepo(map[string]string{
+		"_repo.yaml":  "key: kb\n",
+		"backup.yaml": "type: info\nsummary: バックアップの手順\n",
+	})
+	defer cleanup()
+	r := NewRepo(dir)
+
+	noColor := color.NoColor
+	defer func() { color.NoColor = noColor }()
+	color.NoColor = true
+
+	// Twenty columns, with two between the path and the summary
+	assert.Equal("kb/backup  バックア…\n", searchListing(r.Search("backup", false, Filter{}), 20))
+	assert.Equal("kb/backup  バックアップの手順\n", searchListing(r.Search("backup", false, Filter{}), 0))
+}
+
+func TestSearchAllReverse(t *testing.T) {
+	assert := assert.New(t)
+	dir, cleanup := writeRepo(searchFiles)
+	defer cleanup()
+	r := NewRepo(dir)
+	repos := map[string]*Repo{"search": r}
+
+	var paths []string
+	for _, info := range searchAll(repos, "backups", false, Filter{}, true) {
+		paths = append(paths, info.KeyPath())
+	}
+
+	assert.Equal([]string{
+		r.Key + "/db/retention",
+		r.Key + "/db/backups",
+		r.Key + "/100%/dumps",
+		r.Key + "/restore",
+	}, paths)
+}
+
+func TestSearchReposNothingFound(t *testing.T) {
+	assert := assert.New(t)
+	dir, cleanup := writeRepo(searchFiles)
+	defer cleanup()
+	repos := map[string]*Repo{"search": NewRepo(dir)}
+
+	assert.Equal(exitNotFound, exitCode(func() { SearchRepos(repos, "nowhere", false, Filter{}, false, false) }))
+	assert.Equal(-1, exitCode(func() { SearchRepos(repos, "nowhere", false, Filter{}, true, false) }))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// secretTool is the command that `pass:` references are looked up with, given
+// the path of the secret as its last argument
+var secretTool = "pass show"
+
+// showSecrets makes items print the values of their secrets, and not only
+// their names
+var showSecrets = false
+
+// lookupSecret resolves one secret reference. It is a variable so that tests
+// can use a stub instead of a password manager.
+var lookupSecret = resolveSecret
+
+// resolveSecret resolves a reference like `pass:infra/db`, which is looked up
+// with the secret tool, or `env:DB_PASSWORD`, which is read from the
+// environment
+//
+// Secrets are only ever resolved when they are needed, so that they never end
+// up in the repos.
+func resolveSecret(ref string) (string, error) {
+	parts := strings.SplitN(ref, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", fmt.Errorf("%q is not a secret reference, use pass:<path> or env:<name>", ref)
+	}
+
+	switch parts[0] {
+	case "pass":
+		args := append(strings.Fields(secretTool), parts[1])
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("%s failed: %s", args[0], err)
+		}
+
+		// pass keeps the secret on the first line, and anything else after it
+		return strings.SplitN(string(out), "\n", 2)[0], nil
+
+	case "env":
+		value, ok := os.LookupEnv(parts[1])
+		if !ok {
+			return "", fmt.Errorf("%s is not set", parts[1])
+		}
+		return value, nil
+	}
+
+	return "", fmt.Errorf("Unknown kind of secret %q, use pass:<path> or env:<name>", parts[0])
+}
+
+// resolveSecrets resolves the `secrets` of an item or a host, which map names
+// to references, and stops at the first one that fails
+func resolveSecrets(refs map[string]string) (map[string]string, error) {
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	secrets := make(map[string]string, len(refs))
+	for _, name := range names {
+		value, err := lookupSecret(refs[name])
+		if err != nil {
+			return nil, fmt.Errorf("Could not resolve the secret %s (%s): %s", name, refs[name], err)
+		}
+		secrets[name] = value
+	}
+
+	return secrets, nil
+}
+
+// secretEnv turns resolved secrets into NAME=value variables for the
+// environment of a single command, sorted by name
+//
+// Secrets never replace a variable that is already set, so that one named
+// PATH or HOME cannot break the command.
+func secretEnv(secrets map[string]string) ([]string, error) {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, ok := os.LookupEnv(name); ok {
+			return nil, fmt.Errorf("The secret %s would replace the variable of the same name in the environment", name)
+		}
+		env = append(env, name+"="+secrets[name])
+	}
+	return env, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// stubSecrets replaces lookupSecret with one that knows the given secrets,
+// returning a function that restores it
+func stubSecrets(known map[string]string) func() {
+	orig := lookupSecret
+	lookupSecret = func(ref string) (string, error) {
+		if value, ok := known[ref]; ok {
+			return value, nil
+		}
+		return "", errors.New("not in the store")
+	}
+	return func() { lookupSecret = orig }
+}
+
+func TestResolveSecrets(t *testing.T) {
+	assert := assert.New(t)
+	defer stubSecrets(map[string]string{"pass:infra/db": "hunter2"})()
+
+	secrets, err := resolveSecrets(map[string]string{"PGPASSWORD": "pass:infra/db"})
+	assert.Nil(err)
+	assert.Equal(map[string]string{"PGPASSWORD": "hunter2"}, secrets)
+
+	_, err = resolveSecrets(map[string]string{"TOKEN": "pass:infra/gone"})
+	assert.Equal("Could not resolve the secret TOKEN (pass:infra/gone): not in the store", err.Error())
+}
+
+func TestSecretEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	env, err := secretEnv(map[string]string{"SAGACITY_TEST_USER": "admin", "SAGACITY_TEST_PASSWORD": "hunter2"})
+	assert.Nil(err)
+	assert.Equal([]string{"SAGACITY_TEST_PASSWORD=hunter2", "SAGACITY_TEST_USER=admin"}, env)
+
+	_, err = secretEnv(map[string]string{"PATH": "/tmp"})
+	assert.Contains(err.Error(), "The secret PATH would replace")
+}
+
+func TestHostSecretsOnlyReachItsCommand(t *testing.T) {
+	assert := assert.New(t)
+	defer stubSecrets(map[string]string{"pass:infra/db": "hunter2"})()
+	f := &fakeRunner{}
+	defer useRunner(f)()
+
+	host := &Host{FQDN: "db1.company.net", Secrets: map[string]string{"SAGACITY_TEST_PGPASSWORD": "pass:infra/db"}}
+	assert.Nil(host.Execute("psql"))
+	assert.Nil(host.run(context.Background(), ioutil.Discard, "psql"))
+
+	for x := range f.calls {
+		assert.Equal([]string{"SAGACITY_TEST_PGPASSWORD=hunter2"}, f.envs[x])
+		assert.Contains(f.calls[x], "SendEnv=SAGACITY_TEST_PGPASSWORD")
+	}
+	assert.Equal(2, len(f.calls))
+
+	_, ok := os.LookupEnv("SAGACITY_TEST_PGPASSWORD")
+	assert.False(ok)
+}
+
+func TestResolveSecretFromEnv(t *testing.T) {
+	assert := assert.New(t)
+	os.Setenv("SAGACITY_TEST_SECRET", "s3cret")
+	defer os.Unsetenv("SAGACITY_TEST_SECRET")
+
+	value, err := resolveSecret("env:SAGACITY_TEST_SECRET")
+	assert.Nil(err)
+	assert.Equal("s3cret", value)
+
+	_, err = resolveSecret("env:SAGACITY_TEST_UNSET")
+	assert.Equal("SAGACITY_TEST_UNSET is not set", err.Error())
+
+	_, err = resolveSecret("vault:infra/db")
+	assert.Contains(err.Error(), "Unknown kind of secret")
+}
+
+func TestInfoSecretList(t *testing.T) {
+	assert := assert.New(t)
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	i := Info{secrets: map[string]string{"user": "admin", "password": "hunter2"}}
+
+	assert.Equal("\npassword\nuser\n", i.secretList())
+	assert.Equal("", Info{}.secretList())
+}
+
+func TestInfoSecretListShowSecrets(t *testing.T) {
+	assert := assert.New(t)
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+	defer func(show bool) { showSecrets = show }(showSecrets)
+	showSecrets = true
+
+	i := Info{secrets: map[string]string{"user": "admin", "password": "hunter2"}}
+
+	assert.Equal("\npassword: hunter2\nuser: admin\n", i.secretList())
+}
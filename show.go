@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+)
+
+// ShowItem prints an info item at a path of keys, either as text like
+// executing it does, or as the YAML of its fields
+//
+// Unlike cat, the YAML is made from what was loaded, so it comes out the same
+// way whatever the file looked like. That makes it a good start for a new item.
+func ShowItem(repos map[string]*Repo, path []string, format string) {
+	item, err := findItem(repos, path)
+	if err != nil {
+		fmt.Println(err)
+		exit(exitNotFound)
+	}
+
+	info, ok := item.(*Info)
+	if !ok {
+		fmt.Printf("Only info items can be shown, not %s items\n", item.Type())
+		exit(exitError)
+	}
+
+	switch format {
+	case "text":
+		page(info.text() + info.footer())
+	case "yaml":
+		out, err := info.YAML()
+		if err != nil {
+			fmt.Println("Could not make YAML of", info.ID()+":", err)
+			exit(exitError)
+		}
+		fmt.Print(out)
+	default:
+		fmt.Printf("Unknown format %q, use text or yaml\n", format)
+		exit(exitError)
+	}
+}
+
+// YAML returns the fields of the item as YAML, with any extra metadata after
+// the known fields
+func (i Info) YAML() (string, error) {
+	data, err := yaml.Marshal(i)
+	return string(data), err
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+	"testing"
+)
+
+func TestInfoYAMLRoundTrips(t *testing.T) {
+	assert := assert.New(t)
+	for _, fn := range []string{"test/meta/runbook.yaml", "test/sections/outage.yaml"} {
+		item, _ := LoadItem(&Repo{}, fn)
+		orig := item.(*Info)
+
+		out, err := orig.YAML()
+		assert.Nil(err)
+
+		var again Info
+		assert.Nil(yaml.Unmarshal([]byte(out), &again))
+		assert.Equal(orig.RawType, again.RawType)
+		assert.Equal(orig.RawSummary, again.RawSummary)
+		assert.Equal(orig.Body, again.Body)
+		assert.Equal(orig.Sections, again.Sections)
+		assert.Equal(orig.Extra, again.Extra)
+
+		// Normalized output stays the same when it is normalized again
+		twice, _ := again.YAML()
+		assert.Equal(out, twice)
+	}
+}
+
+func TestInfoYAMLLeavesOutEmptyFields(t *testing.T) {
+	assert := assert.New(t)
+	item, _ := LoadItem(&Repo{}, "test/meta/runbook.yaml")
+
+	out, _ := item.(*Info).YAML()
+
+	assert.NotContains(out, "url:")
+	assert.NotContains(out, "order:")
+	assert.Contains(out, "owner: payments-team\n")
+}
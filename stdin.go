@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// readHosts reads one FQDN per line, for running commands on hosts picked by
+// another program
+//
+// Hosts that are defined in the repos get the options of their first
+// definition. Anything else is taken to be an FQDN that no repo knows about,
+// and only gets the ssh defaults of the configuration. Blank lines and
+// comments are skipped.
+func readHosts(repos map[string]*Repo, conf *Config, r io.Reader) ([]Host, error) {
+	var hosts []Host
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if matches := FindHost(repos, line); len(matches) != 0 {
+			hosts = append(hosts, matches[0].Host)
+		} else {
+			hosts = append(hosts, *directHost(conf, line))
+		}
+	}
+
+	return hosts, scanner.Err()
+}
+
+// ExecStdin runs a command on every host listed on stdin, such as
+// `grep prod hosts.txt | sagacity exec --stdin -- uptime`
+func ExecStdin(repos map[string]*Repo, conf *Config, command string, opts FanOutOptions) {
+	hosts, err := readHosts(repos, conf, os.Stdin)
+	if err != nil {
+		fmt.Println("Could not read the hosts:", err)
+		exit(exitError)
+	}
+	if len(hosts) == 0 {
+		fmt.Println("No hosts given on stdin")
+		exit(exitError)
+	}
+
+	ExecuteCommand(hosts, command, opts)
+}
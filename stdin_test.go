@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestReadHostsFromPipe(t *testing.T) {
+	assert := assert.New(t)
+	repos := map[string]*Repo{"printout": NewRepo("test/repos/host_tests/printout/")}
+	conf := &Config{SSHDefaults: SSHOptions{User: "deploy"}}
+
+	r, w := io.Pipe()
+	go func() {
+		fmt.Fprintln(w, "db4.cluster3.company.net")
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, "# not a host")
+		fmt.Fprintln(w, "  adhoc.company.net  ")
+		w.Close()
+	}()
+
+	hosts, err := readHosts(repos, conf, r)
+
+	assert.Nil(err)
+	assert.Equal(2, len(hosts))
+	assert.Equal("longquery", hosts[0].Kind)
+	assert.Equal("adhoc.company.net", hosts[1].FQDN)
+	assert.Equal("deploy", hosts[1].User)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// OpenPanes opens ssh connections to several hosts at once
+//
+// A new tmux window is created for the first host, and the window is then
+// split once for every other host. The layout is retiled after every split so
+// that there is always room for the next pane.
+func OpenPanes(hosts []Host) error {
+	if len(hosts) == 0 {
+		return errors.New("No hosts to open")
+	}
+
+	tmux, err := exec.LookPath("tmux")
+	if err != nil {
+		return errors.New("tmux is needed to open several hosts at once, but it was not found")
+	}
+
+	if os.Getenv("TMUX") == "" {
+		return errors.New("Opening several hosts at once only works from inside of tmux")
+	}
+
+	for _, args := range paneCommands(hosts) {
+		cmd := exec.Command(tmux, args...)
+		cmd.Stderr = os.Stderr
+
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("tmux %s failed: %s", args[0], err)
+		}
+	}
+
+	return nil
+}
+
+// paneCommands returns the tmux commands needed to open the hosts in panes
+func paneCommands(hosts []Host) [][]string {
+	cmds := make([][]string, 0, len(hosts)*2)
+	for n, host := range hosts {
+		args := host.command()
+		for x, arg := range args {
+			args[x] = shellQuote(arg)
+		}
+		line := strings.Join(args, " ")
+
+		if n == 0 {
+			cmds = append(cmds, []string{"new-window", line})
+			continue
+		}
+
+		cmds = append(
+			cmds,
+			[]string{"split-window", line},
+			[]string{"select-layout", "tiled"},
+		)
+	}
+
+	return cmds
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPaneCommandsOpensOneWindowAndSplitsIt(t *testing.T) {
+	assert := assert.New(t)
+	hosts := []Host{
+		{FQDN: "web1.company.net"},
+		{FQDN: "web2.company.net"},
+		{FQDN: "web3.company.net"},
+	}
+
+	cmds := paneCommands(hosts)
+
+	assert.Equal([][]string{
+		{"new-window", "ssh web1.company.net -A -t"},
+		{"split-window", "ssh web2.company.net -A -t"},
+		{"select-layout", "tiled"},
+		{"split-window", "ssh web3.company.net -A -t"},
+		{"select-layout", "tiled"},
+	}, cmds)
+}
+
+func TestOpenPanesWithoutHosts(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NotNil(OpenPanes([]Host{}))
+}
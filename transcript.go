@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// transcriptDir is where sessions are recorded, if anywhere. Nothing is
+// recorded unless `transcripts` is set in the configuration.
+var transcriptDir string
+
+// startTranscript creates an empty transcript file for a session with a host,
+// named after the host and the time
+//
+// Transcripts can contain anything typed or shown in the session, passwords
+// included, so only the user can read them.
+func startTranscript(dir, fqdn string, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	fn := filepath.Join(dir, fqdn+"-"+now.Format("20060102T150405")+".log")
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	return fn, f.Close()
+}
+
+// transcriptArgs wraps a command line in script(1), so that everything in the
+// session is written to the file as well
+//
+// script gives the command a terminal of its own, so interactive sessions work
+// just like they do without it. It takes different arguments on BSD and macOS
+// than on Linux, where -e is needed for it to exit with the status of the
+// command instead of 0.
+func transcriptArgs(goos string, args []string, fn string) []string {
+	if goos == "darwin" || strings.HasSuffix(goos, "bsd") {
+		return append([]string{"script", "-q", fn}, args...)
+	}
+
+	quoted := make([]string, len(args))
+	for x, arg := range args {
+		quoted[x] = shellQuote(arg)
+	}
+	return []string{"script", "-q", "-e", "-f", "-c", strings.Join(quoted, " "), fn}
+}
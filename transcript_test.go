@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStartTranscript(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "sagacity")
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2016, 3, 15, 12, 30, 0, 0, time.Local)
+	fn, err := startTranscript(filepath.Join(dir, "transcripts"), "db1.company.net", now)
+
+	assert.Nil(err)
+	assert.Equal(filepath.Join(dir, "transcripts", "db1.company.net-20160315T123000.log"), fn)
+
+	fi, _ := os.Stat(fn)
+	assert.Equal(os.FileMode(0600), fi.Mode().Perm())
+	fi, _ = os.Stat(filepath.Join(dir, "transcripts"))
+	assert.Equal(os.FileMode(0700), fi.Mode().Perm())
+}
+
+func TestTranscriptArgs(t *testing.T) {
+	assert := assert.New(t)
+	args := []string{"ssh", "db1.company.net", "-A", "-t", "-o", "LogLevel=ERROR quiet"}
+
+	assert.Equal(
+		[]string{"script", "-q", "-e", "-f", "-c", "ssh db1.company.net -A -t -o 'LogLevel=ERROR quiet'", "db1.log"},
+		transcriptArgs("linux", args, "db1.log"),
+	)
+	assert.Equal(
+		append([]string{"script", "-q", "db1.log"}, args...),
+		transcriptArgs("darwin", args, "db1.log"),
+	)
+}
+
+func TestHostExecuteWithTranscript(t *testing.T) {
+	assert := assert.New(t)
+	f := &fakeRunner{}
+	defer useRunner(f)()
+
+	dir, _ := ioutil.TempDir("", "sagacity")
+	defer os.RemoveAll(dir)
+	defer func() { transcriptDir = "" }()
+	transcriptDir = dir
+
+	host := &Host{FQDN: "web1.company.net"}
+	host.Execute()
+
+	assert.Equal("script", f.calls[0][0])
+	files, _ := ioutil.ReadDir(dir)
+	assert.Equal(1, len(files))
+}
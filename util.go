@@ -1,40 +1,78 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// gitTimeout is how long a git command gets before it is killed, so that an
+// unreachable remote does not hang everything
+var gitTimeout = 10 * time.Minute
+
+// gitBinary is the git that is run. Tests replace it with a fake one.
+var gitBinary = "git"
+
+// runGit runs git with the arguments in pwd, after setup has had a chance to
+// set up the outputs of the command
+//
+// If it takes longer than gitTimeout, it is killed and reported as having
+// timed out.
+func runGit(pwd string, setup func(cmd *exec.Cmd), args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, gitBinary, args...)
+	cmd.Dir = pwd
+	setup(cmd)
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("git %s timed out after %s", args[0], gitTimeout)
+	}
+	return err
+}
+
 // Helper for executing git commands
 func git(pwd string, args ...string) {
+	if err := gitRun(pwd, args...); err != nil {
+		log.Println("git command failed - aborting:", err)
+		exit(exitGitError)
+	}
+}
+
+// gitRun executes a git command, returning any failure instead of exiting
+func gitRun(pwd string, args ...string) error {
 	if pwd == "" {
 		pwd, _ = os.Getwd()
 	}
-	git, err := exec.LookPath("git")
-	if err != nil {
-		log.Fatal("no git :'(   ", err)
+	if _, err := exec.LookPath(gitBinary); err != nil {
+		return fmt.Errorf("no git :'(   %s", err)
 	}
 
-	// why................
-	args = append([]string{git}, args...)
+	return runGit(pwd, func(cmd *exec.Cmd) {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	}, args...)
+}
 
-	cmd := exec.Cmd{
-		Path:   git,
-		Args:   args,
-		Dir:    pwd,
-		Stdout: os.Stdout,
-		Stderr: os.Stderr,
-	}
+// gitOutput runs a git command and returns what it printed
+func gitOutput(pwd string, args ...string) (string, error) {
+	var out bytes.Buffer
+	err := runGit(pwd, func(cmd *exec.Cmd) {
+		cmd.Stdout = &out
+		cmd.Stderr = os.Stderr
+	}, args...)
 
-	err = cmd.Run()
-	if err != nil {
-		log.Println("git command failed - aborting")
-		log.Fatal(err)
-	}
+	return strings.TrimSpace(out.String()), err
 }
 
 func ask(prompt string) bool {
@@ -44,7 +82,8 @@ func ask(prompt string) bool {
 	_, err := fmt.Scanln(&resp)
 	if err != nil {
 		if err.Error() != "unexpected newline" && err.Error() != "EOF" {
-			log.Fatal(err)
+			fmt.Println(err)
+			exit(exitError)
 		} else {
 			return false
 		}
@@ -57,14 +96,53 @@ func ask(prompt string) bool {
 	return false
 }
 
+// choose asks the user to pick a number from 0 to n-1
+//
+// The second return value is false if nothing valid was picked.
+func choose(prompt string, n int) (int, bool) {
+	var resp string
+
+	fmt.Print(prompt)
+	if _, err := fmt.Scanln(&resp); err != nil {
+		return 0, false
+	}
+
+	x, err := strconv.Atoi(resp)
+	if err != nil || x < 0 || x >= n {
+		return 0, false
+	}
+
+	fmt.Println() // To separate output and prompt
+	return x, true
+}
+
+// safeRxp matches strings that the shell will not split or expand
+var safeRxp = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes a string so that a POSIX shell sees it as one word
+func shellQuote(s string) string {
+	if safeRxp.MatchString(s) {
+		return s
+	}
+
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
+
 func getPath(p string) string {
 	path, _ := filepath.Abs(p)
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		log.Fatal(err)
+	if _, err := repoFS.Stat(path); os.IsNotExist(err) {
+		fmt.Println(err)
+		exit(exitNotFound)
 	}
 	return path
 }
 
+// isDir returns true if the path is a directory, following symlinks
+func isDir(p string) bool {
+	fi, err := repoFS.Stat(p)
+	return err == nil && fi.IsDir()
+}
+
 func asKey(p string) string {
 	basename := filepath.Base(p)
 	return strings.TrimSuffix(basename, filepath.Ext(basename))
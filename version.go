@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// The version, commit and build date are set when building releases:
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.date=$(date -u +%Y-%m-%d)"
+var (
+	version = "dev"
+	commit  = "unknown"
+	date    = "unknown"
+)
+
+// versionInfo returns everything that is known about the build
+func versionInfo() string {
+	return fmt.Sprintf(
+		"sagacity %s (commit %s, built %s, %s)",
+		version, commit, date, runtime.Version(),
+	)
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"testing"
+)
+
+func TestVersionInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	orig := version
+	defer func() { version = orig }()
+	version = "1.2.0"
+
+	assert.Equal(
+		"sagacity 1.2.0 (commit unknown, built unknown, "+runtime.Version()+")",
+		versionInfo(),
+	)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+)
+
+// fullWidth turns off the truncation of summaries, for --full
+var fullWidth bool
+
+// maxWidth is the widest that output is allowed to be, even on a wider
+// terminal. Zero means the width of the terminal.
+var maxWidth int
+
+// outputCols returns the number of columns that summaries are truncated to fit
+// in, or zero if they should not be truncated
+//
+// Only output to a terminal is truncated, since long lines are fine anywhere
+// else.
+func outputCols() int {
+	if fullWidth || !isTerminal(os.Stdout) {
+		return 0
+	}
+
+	_, cols := terminalSize()
+	if maxWidth > 0 && maxWidth < cols {
+		cols = maxWidth
+	}
+	return cols
+}
+
+// wideRanges are the ranges of runes that take up two columns on a terminal:
+// CJK, Hangul, fullwidth forms and most emoji
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},
+	{0x2E80, 0x303E},
+	{0x3041, 0x33FF},
+	{0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF},
+	{0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF},
+	{0xFE30, 0xFE4F},
+	{0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6},
+	{0x1F300, 0x1F64F},
+	{0x1F900, 0x1F9FF},
+	{0x20000, 0x3FFFD},
+}
+
+// runeWidth returns the number of columns a rune takes up on a terminal
+func runeWidth(r rune) int {
+	for _, wide := range wideRanges {
+		if r >= wide[0] && r <= wide[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// displayWidth returns the number of columns a string takes up on a terminal
+func displayWidth(s string) (n int) {
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return
+}
+
+// truncate cuts a string down to at most n columns, marking that it was cut
+// with an ellipsis
+//
+// Wide characters count as two columns, and are never cut in half.
+func truncate(s string, n int) string {
+	if displayWidth(s) <= n {
+		return s
+	}
+	if n < 1 {
+		return ""
+	}
+
+	// Leave room for the ellipsis
+	cols := 0
+	for x, r := range s {
+		if cols+runeWidth(r) > n-1 {
+			return s[:x] + "…"
+		}
+		cols += runeWidth(r)
+	}
+	return s
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDisplayWidth(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(5, displayWidth("hello"))
+	assert.Equal(5, displayWidth("héllo"))
+	assert.Equal(8, displayWidth("日本語db"))
+	assert.Equal(4, displayWidth("🔥🔥"))
+}
+
+func TestTruncate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("short", truncate("short", 10))
+	assert.Equal("Restart t…", truncate("Restart the service", 10))
+	assert.Equal("héllo wo…", truncate("héllo world", 9))
+	assert.Equal("", truncate("anything", 0))
+}
+
+func TestTruncateWideCharacters(t *testing.T) {
+	assert := assert.New(t)
+
+	// Each character is two columns wide, so with one column for the
+	// ellipsis only two of them fit in six, and the third is not cut in half
+	assert.Equal("デー…", truncate("データベース", 6))
+	assert.Equal("データ…", truncate("データベース", 7))
+	assert.Equal("データベース", truncate("データベース", 12))
+	assert.Equal("🔥…", truncate("🔥🔥🔥", 4))
+}